@@ -0,0 +1,64 @@
+package JWTParser
+
+import (
+	"cmp"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"slices"
+	"strings"
+)
+
+// Claims - стандартный набор claims: зарегистрированные claims RFC 7519, scopes, роли и тенант
+type Claims struct {
+	jwt.RegisteredClaims
+	// Scopes из claim scope (строка через пробел, RFC 8693) или scp (строка или массив)
+	Scopes []string `json:"-"`
+	// Roles из claim roles или role (строка или массив)
+	Roles []string `json:"-"`
+	// Tenant из claim tenant, tenant_id или tid
+	Tenant string `json:"-"`
+}
+
+// HasScope сообщает, выдан ли токену scope
+func (c *Claims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// HasRole сообщает, есть ли у владельца токена роль
+func (c *Claims) HasRole(role string) bool {
+	return slices.Contains(c.Roles, role)
+}
+
+// claimsJSON - формат Claims в теле токена
+type claimsJSON struct {
+	jwt.RegisteredClaims
+	Scope string           `json:"scope,omitempty"`
+	Scp   jwt.ClaimStrings `json:"scp,omitempty"`
+	Roles jwt.ClaimStrings `json:"roles,omitempty"`
+	Role  jwt.ClaimStrings `json:"role,omitempty"`
+
+	Tenant   string `json:"tenant,omitempty"`
+	TenantID string `json:"tenant_id,omitempty"`
+	Tid      string `json:"tid,omitempty"`
+}
+
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	var raw claimsJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	c.RegisteredClaims = raw.RegisteredClaims
+	c.Scopes = append(strings.Fields(raw.Scope), raw.Scp...)
+	c.Roles = append(raw.Roles, raw.Role...)
+	c.Tenant = cmp.Or(raw.Tenant, raw.TenantID, raw.Tid)
+	return nil
+}
+
+func (c Claims) MarshalJSON() ([]byte, error) {
+	return json.Marshal(claimsJSON{
+		RegisteredClaims: c.RegisteredClaims,
+		Scope:            strings.Join(c.Scopes, " "),
+		Roles:            c.Roles,
+		Tenant:           c.Tenant,
+	})
+}
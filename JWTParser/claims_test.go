@@ -0,0 +1,80 @@
+package JWTParser
+
+import (
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		TestName    string
+		claims      jwt.MapClaims
+		wantScopes  []string
+		wantRoles   []string
+		wantTenant  string
+		wantSubject string
+	}{
+		{
+			TestName:    "ScopeStringAndRolesArray",
+			claims:      jwt.MapClaims{"sub": "user-1", "scope": "read write", "roles": []string{"admin", "owner"}, "tenant": "acme"},
+			wantScopes:  []string{"read", "write"},
+			wantRoles:   []string{"admin", "owner"},
+			wantTenant:  "acme",
+			wantSubject: "user-1",
+		},
+		{
+			TestName:    "ScpArrayAndSingleRole",
+			claims:      jwt.MapClaims{"sub": "user-2", "scp": []string{"read"}, "role": "viewer", "tid": "tenant-guid"},
+			wantScopes:  []string{"read"},
+			wantRoles:   []string{"viewer"},
+			wantTenant:  "tenant-guid",
+			wantSubject: "user-2",
+		},
+		{
+			TestName:    "TenantID",
+			claims:      jwt.MapClaims{"sub": "service", "tenant_id": "42"},
+			wantTenant:  "42",
+			wantSubject: "service",
+		},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.TestName, func(t *testing.T) {
+			tt.claims["exp"] = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+			token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, tt.claims).SignedString([]byte("secret"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := Parse[*Claims](token, log)
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if claims.Subject != tt.wantSubject || claims.Tenant != tt.wantTenant {
+				t.Errorf("got subject %q tenant %q, want %q %q", claims.Subject, claims.Tenant, tt.wantSubject, tt.wantTenant)
+			}
+			if !slices.Equal(claims.Scopes, tt.wantScopes) || !slices.Equal(claims.Roles, tt.wantRoles) {
+				t.Errorf("got scopes %v roles %v, want %v %v", claims.Scopes, claims.Roles, tt.wantScopes, tt.wantRoles)
+			}
+			if !claims.ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("got expiry %v", claims.ExpiresAt)
+			}
+
+			mapClaims, err := Parse[jwt.MapClaims](token, log)
+			if err != nil {
+				t.Fatalf("Parse[jwt.MapClaims] failed: %v", err)
+			}
+			if mapClaims["sub"] != tt.wantSubject {
+				t.Errorf("got map claims %v", mapClaims)
+			}
+		})
+	}
+
+	if _, err := Parse[*Claims]("invalid", log); err == nil {
+		t.Error("expected error for malformed token")
+	}
+}
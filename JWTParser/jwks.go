@@ -0,0 +1,299 @@
+package JWTParser
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrKeyNotFound в наборе нет ключа для токена
+var ErrKeyNotFound = errors.New("signing key not found")
+
+// KeySet - источник ключей для проверки подписи токенов
+type KeySet interface {
+	// Key возвращает ключ проверки подписи по kid и алгоритму из заголовка токена.
+	// Тип ключа зависит от алгоритма: *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey или []byte для HMAC.
+	Key(ctx context.Context, kid string, alg string) (any, error)
+}
+
+// Key - ключ набора
+type Key struct {
+	// ID идентификатор ключа (kid)
+	ID string
+	// Algorithm алгоритм, для которого предназначен ключ. Если пуст, подходит любой алгоритм этого типа ключа
+	Algorithm string
+	// Key *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey или []byte для HMAC
+	Key any
+}
+
+// StaticKeySet - неизменяемый набор ключей, заданный при создании
+type StaticKeySet struct {
+	keys []Key
+}
+
+// NewStaticKeySet создаёт набор из заданных ключей
+func NewStaticKeySet(keys ...Key) *StaticKeySet {
+	return &StaticKeySet{keys: keys}
+}
+
+// errUnsupportedKey - тип ключа или кривая не поддерживаются, такой ключ пропускается
+var errUnsupportedKey = errors.New("unsupported key")
+
+// ParseJWKS разбирает JSON Web Key Set (RFC 7517).
+// Поддерживаются ключи RSA, EC (P-256, P-384, P-521), OKP (Ed25519) и oct (HMAC).
+// Ключи неизвестных типов и кривых (например secp256k1 или X25519) и ключи шифрования (use=enc) пропускаются
+// с предупреждением в slog.Default(), ошибкой считается только повреждённый ключ поддерживаемого типа.
+func ParseJWKS(data []byte) (*StaticKeySet, error) {
+	return parseJWKS(data, slog.Default())
+}
+
+func parseJWKS(data []byte, log *slog.Logger) (*StaticKeySet, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("parse jwks: %w", err)
+	}
+	keys := make([]Key, 0, len(set.Keys))
+	for _, raw := range set.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		key, err := raw.publicKey()
+		if errors.Is(err, errUnsupportedKey) {
+			log.Warn("skipping unsupported jwk", slog.String("op", "JWTParser.ParseJWKS"),
+				slog.String("kid", raw.Kid), slog.String("error", err.Error()))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse jwk %q: %w", raw.Kid, err)
+		}
+		keys = append(keys, Key{ID: raw.Kid, Algorithm: raw.Alg, Key: key})
+	}
+	return NewStaticKeySet(keys...), nil
+}
+
+// Key ищет ключ по kid. Если kid в токене не указан, подходит единственный ключ, совместимый с алгоритмом.
+func (s *StaticKeySet) Key(_ context.Context, kid string, alg string) (any, error) {
+	var found []Key
+	for _, key := range s.keys {
+		if kid != "" && key.ID != kid {
+			continue
+		}
+		if !keyMatchesAlg(key, alg) {
+			continue
+		}
+		found = append(found, key)
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("%w: kid %q, alg %q", ErrKeyNotFound, kid, alg)
+	}
+	return found[0].Key, nil
+}
+
+// keyMatchesAlg проверяет, что тип ключа подходит алгоритму токена.
+// Защищает от подмены алгоритма, например проверки HS256 открытым RSA ключом.
+func keyMatchesAlg(key Key, alg string) bool {
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return false
+	}
+	switch key.Key.(type) {
+	case *rsa.PublicKey:
+		return len(alg) == 5 && (alg[:2] == "RS" || alg[:2] == "PS")
+	case *ecdsa.PublicKey:
+		return len(alg) == 5 && alg[:2] == "ES"
+	case ed25519.PublicKey:
+		return alg == "EdDSA"
+	case []byte:
+		return len(alg) == 5 && alg[:2] == "HS"
+	}
+	return false
+}
+
+// RemoteKeySet - набор ключей, загружаемый по JWKS URL.
+// Ключи кешируются на CacheTTL. Если в кеше нет ключа с kid из токена (ключи ротировали),
+// набор загружается заново, но не чаще, чем раз в MinRefreshInterval.
+type RemoteKeySet struct {
+	url    string
+	client *http.Client
+
+	// CacheTTL время жизни загруженного набора
+	CacheTTL time.Duration
+	// MinRefreshInterval минимальный интервал между загрузками при поиске неизвестного kid
+	MinRefreshInterval time.Duration
+	// Clock источник времени для CacheTTL и MinRefreshInterval
+	Clock clock.Clock
+	// Logger получает предупреждения о пропущенных ключах, по умолчанию slog.Default()
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	keys      *StaticKeySet
+	fetchedAt time.Time
+}
+
+// NewRemoteKeySet создаёт набор ключей, загружаемый по url.
+// Если client равен nil, используется клиент с таймаутом 10 секунд.
+func NewRemoteKeySet(url string, client *http.Client) *RemoteKeySet {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &RemoteKeySet{
+		url:                url,
+		client:             client,
+		CacheTTL:           time.Hour,
+		MinRefreshInterval: time.Minute,
+		Clock:              clock.System,
+		Logger:             slog.Default(),
+	}
+}
+
+func (r *RemoteKeySet) Key(ctx context.Context, kid string, alg string) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.keys == nil || r.Clock.Now().Sub(r.fetchedAt) > r.CacheTTL {
+		if err := r.fetchUnlocked(ctx); err != nil {
+			return nil, err
+		}
+	}
+	key, err := r.keys.Key(ctx, kid, alg)
+	if errors.Is(err, ErrKeyNotFound) && r.Clock.Now().Sub(r.fetchedAt) >= r.MinRefreshInterval {
+		if err := r.fetchUnlocked(ctx); err != nil {
+			return nil, err
+		}
+		return r.keys.Key(ctx, kid, alg)
+	}
+	return key, err
+}
+
+func (r *RemoteKeySet) fetchUnlocked(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys, err := parseJWKS(data, r.Logger)
+	if err != nil {
+		return err
+	}
+	r.keys = keys
+	r.fetchedAt = r.Clock.Now()
+	return nil
+}
+
+// jwk - ключ в формате RFC 7517
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC и OKP
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// oct
+	K string `json:"k"`
+}
+
+// publicKey возвращает ключ проверки подписи или errUnsupportedKey для неподдерживаемых типов и кривых
+func (k jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("rsa exponent is too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		// ECDH проверяет, что точка лежит на кривой
+		if _, err := key.ECDH(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("%w: curve %q", errUnsupportedKey, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, err
+		}
+		return secret, nil
+	}
+	return nil, fmt.Errorf("%w: kty %q", errUnsupportedKey, k.Kty)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -2,39 +2,77 @@ package JWTParser
 
 import (
 	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/redact"
 	"github.com/golang-jwt/jwt/v5"
 	"log/slog"
+	"reflect"
 	"time"
 )
 
+// Parse разбирает токен без проверки подписи и декодирует claims в T.
+// T - указатель на структуру, реализующую jwt.Claims (например *Claims), или jwt.MapClaims.
+// Для проверки подписи используйте Verifier.VerifyClaims.
+func Parse[T jwt.Claims](tokenString string, log *slog.Logger) (T, error) {
+	const op = "JWTParser.Parse"
+	claims := newClaims[T]()
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		log.Error("parsing error", slog.String("operation", op), slog.String("error", err.Error()))
+		var zero T
+		return zero, err
+	}
+	return claims, nil
+}
+
+// newClaims создаёт пустое значение T, в которое можно декодировать claims
+func newClaims[T jwt.Claims]() T {
+	var claims T
+	switch t := reflect.TypeOf(&claims).Elem(); t.Kind() {
+	case reflect.Pointer:
+		return reflect.New(t.Elem()).Interface().(T)
+	case reflect.Map:
+		return reflect.MakeMap(t).Interface().(T)
+	}
+	return claims
+}
+
 func ParseUnverified(tokenString string, log *slog.Logger) (jwt.MapClaims, error) {
 	const op = "requests.ParseUnverified"
-	log = slog.With(
-		slog.String("token", tokenString),
-		slog.String("operation", op))
 	parser := jwt.NewParser()
 	token, _, err := parser.ParseUnverified(tokenString, jwt.MapClaims{})
 	if err != nil {
-		log.Error("parsing error", slog.String("error", err.Error()))
+		// Токен логируется только отпечатком, чтобы его нельзя было взять из логов
+		log.Error("parsing error", slog.String("operation", op),
+			slog.Any("token", redact.Fingerprint(tokenString)), slog.String("error", err.Error()))
 		return nil, err
 	}
 	return token.Claims.(jwt.MapClaims), nil
 }
 
+// IsTokenExpired сообщает, истёк ли токен по системному времени.
+// Для проверки по clock.Clock используйте IsTokenExpiredAt(claims, c.Now()).
 func IsTokenExpired(claims jwt.MapClaims) bool {
+	return IsTokenExpiredAt(claims, time.Now())
+}
+
+// IsTokenExpiredAt сообщает, истечёт ли токен к моменту at.
+// Чтобы заранее отбраковать почти истёкший токен, передайте текущее время плюс допуск.
+// Токен без claim exp не истекает.
+func IsTokenExpiredAt(claims jwt.MapClaims, at time.Time) bool {
 	exp, err := claims.GetExpirationTime()
 	if err != nil {
 		return true
 	}
-	return exp.Before(time.Now())
+	if exp == nil {
+		return false
+	}
+	return exp.Before(at)
 }
 
 // GetExpirationTime возвращает время истечения токена и флаг валидности
 func GetExpirationTime(claims jwt.MapClaims, log *slog.Logger) (time.Time, error) {
 	const op = "JWTParser.GetExpirationTime"
-	log = slog.With(
-		slog.String("operation", op),
-		slog.String("claims", fmt.Sprintf("%v", claims)))
+	log = log.With(
+		slog.String("operation", op))
 
 	exp, err := claims.GetExpirationTime()
 	if err != nil {
@@ -2,6 +2,7 @@ package JWTParser
 
 import (
 	"bytes"
+	"github.com/golang-jwt/jwt/v5"
 	"log/slog"
 	"strings"
 	"testing"
@@ -116,3 +117,26 @@ func TestGetExpirationTime(t *testing.T) {
 		})
 	}
 }
+
+func TestIsTokenExpiredAt(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		TestName string
+		claims   jwt.MapClaims
+		at       time.Time
+		expected bool
+	}{
+		{"ValidToken", jwt.MapClaims{"exp": float64(now.Add(time.Hour).Unix())}, now, false},
+		{"ExpiredToken", jwt.MapClaims{"exp": float64(now.Add(-time.Minute).Unix())}, now, true},
+		{"ExpiresWithinSkew", jwt.MapClaims{"exp": float64(now.Add(5 * time.Second).Unix())}, now.Add(10 * time.Second), true},
+		{"NoExpiration", jwt.MapClaims{"sub": "user"}, now, false},
+		{"InvalidExpiration", jwt.MapClaims{"exp": "tomorrow"}, now, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.TestName, func(t *testing.T) {
+			if got := IsTokenExpiredAt(tt.claims, tt.at); got != tt.expected {
+				t.Errorf("IsTokenExpiredAt() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package JWTParser
+
+import (
+	"context"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"github.com/golang-jwt/jwt/v5"
+	"time"
+)
+
+// Verifier проверяет подпись токена ключом из KeySet и стандартные claims:
+// exp, nbf и, если заданы, iss и aud.
+type Verifier struct {
+	keys       KeySet
+	issuer     string
+	audience   []string
+	algorithms []string
+	leeway     time.Duration
+	clock      clock.Clock
+	// expirationRequired отклоняет токены без claim exp
+	expirationRequired bool
+}
+
+// VerifierOption настраивает Verifier
+type VerifierOption func(*Verifier)
+
+// WithIssuer требует совпадения claim iss
+func WithIssuer(issuer string) VerifierOption {
+	return func(v *Verifier) {
+		v.issuer = issuer
+	}
+}
+
+// WithAudience требует, чтобы claim aud содержал хотя бы одно из значений
+func WithAudience(audience ...string) VerifierOption {
+	return func(v *Verifier) {
+		v.audience = audience
+	}
+}
+
+// WithAlgorithms ограничивает допустимые алгоритмы подписи.
+// По умолчанию допускается любой алгоритм, подходящий к типу ключа, кроме none.
+func WithAlgorithms(algorithms ...string) VerifierOption {
+	return func(v *Verifier) {
+		v.algorithms = algorithms
+	}
+}
+
+// WithLeeway задаёт допустимое расхождение часов при проверке exp и nbf
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// WithExpirationRequired отклоняет токены без claim exp. По умолчанию exp проверяется, только если он есть,
+// и токен без exp не истекает никогда.
+func WithExpirationRequired() VerifierOption {
+	return func(v *Verifier) {
+		v.expirationRequired = true
+	}
+}
+
+// WithClock задаёт источник текущего времени для проверки exp, nbf и iat, по умолчанию clock.System
+func WithClock(c clock.Clock) VerifierOption {
+	return func(v *Verifier) {
+		v.clock = c
+	}
+}
+
+// NewVerifier создаёт проверку подписи по набору ключей.
+// Для JWKS URL используйте NewRemoteKeySet, для заданных ключей - NewStaticKeySet или ParseJWKS.
+func NewVerifier(keys KeySet, opts ...VerifierOption) *Verifier {
+	v := &Verifier{keys: keys, clock: clock.System}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify проверяет подпись и claims токена и возвращает его claims
+func (v *Verifier) Verify(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	if err := v.VerifyClaims(ctx, tokenString, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// VerifyClaims проверяет подпись и claims токена и декодирует их в claims, например *Claims
+func (v *Verifier) VerifyClaims(ctx context.Context, tokenString string, claims jwt.Claims) error {
+	parserOpts := []jwt.ParserOption{jwt.WithLeeway(v.leeway), jwt.WithTimeFunc(v.clock.Now)}
+	if len(v.algorithms) > 0 {
+		parserOpts = append(parserOpts, jwt.WithValidMethods(v.algorithms))
+	}
+	if v.issuer != "" {
+		parserOpts = append(parserOpts, jwt.WithIssuer(v.issuer))
+	}
+	if v.expirationRequired {
+		parserOpts = append(parserOpts, jwt.WithExpirationRequired())
+	}
+	token, err := jwt.NewParser(parserOpts...).ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return v.keys.Key(ctx, kid, token.Method.Alg())
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrTokenSignatureInvalid
+	}
+	// jwt.WithAudience требует все значения, а здесь достаточно одного
+	if len(v.audience) > 0 {
+		aud, err := claims.GetAudience()
+		if err != nil {
+			return err
+		}
+		if !containsAny(aud, v.audience) {
+			return fmt.Errorf("%w: got %v, want one of %v", jwt.ErrTokenInvalidAudience, []string(aud), v.audience)
+		}
+	}
+	return nil
+}
+
+func containsAny(values []string, wanted []string) bool {
+	for _, value := range values {
+		for _, want := range wanted {
+			if value == want {
+				return true
+			}
+		}
+	}
+	return false
+}
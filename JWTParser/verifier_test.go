@@ -0,0 +1,253 @@
+package JWTParser
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSigner - ключ подписи и его публичная часть в формате JWK
+type testSigner struct {
+	kid    string
+	method jwt.SigningMethod
+	key    crypto.PrivateKey
+	jwk    map[string]string
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func newRSASigner(t *testing.T, kid string) testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{kid, jwt.SigningMethodRS256, key, map[string]string{
+		"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+		"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+	}}
+}
+
+func newECSigner(t *testing.T, kid string) testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{kid, jwt.SigningMethodES256, key, map[string]string{
+		"kty": "EC", "kid": kid, "crv": "P-256",
+		"x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32))),
+	}}
+}
+
+func newEd25519Signer(t *testing.T, kid string) testSigner {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigner{kid, jwt.SigningMethodEdDSA, private, map[string]string{
+		"kty": "OKP", "kid": kid, "crv": "Ed25519", "x": b64(public),
+	}}
+}
+
+func newHMACSigner(kid string, secret string) testSigner {
+	return testSigner{kid, jwt.SigningMethodHS256, []byte(secret), map[string]string{
+		"kty": "oct", "kid": kid, "k": b64([]byte(secret)),
+	}}
+}
+
+func (s testSigner) sign(t *testing.T, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(s.method, claims)
+	token.Header["kid"] = s.kid
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func jwksJSON(t *testing.T, signers ...testSigner) []byte {
+	keys := make([]map[string]string, 0, len(signers))
+	for _, signer := range signers {
+		keys = append(keys, signer.jwk)
+	}
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss": "https://idp.example",
+		"aud": []string{"api", "other"},
+		"nbf": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	rsaSigner := newRSASigner(t, "rsa")
+	ecSigner := newECSigner(t, "ec")
+	edSigner := newEd25519Signer(t, "ed")
+	hmacSigner := newHMACSigner("hmac", "secret")
+	keys, err := ParseJWKS(jwksJSON(t, rsaSigner, ecSigner, edSigner, hmacSigner))
+	if err != nil {
+		t.Fatalf("ParseJWKS failed: %v", err)
+	}
+	verifier := NewVerifier(keys, WithIssuer("https://idp.example"), WithAudience("api"))
+
+	// Токен с kid RSA ключа, но подписанный HS256 его открытым ключом (подмена алгоритма)
+	confused := newHMACSigner("rsa", rsaSigner.jwk["n"])
+	unknown := newHMACSigner("unknown", "secret")
+
+	tests := []struct {
+		testName string
+		signer   testSigner
+		claims   func(jwt.MapClaims)
+		wantErr  error
+	}{
+		{"PositiveRSA", rsaSigner, nil, nil},
+		{"PositiveECDSA", ecSigner, nil, nil},
+		{"PositiveEdDSA", edSigner, nil, nil},
+		{"PositiveHMAC", hmacSigner, nil, nil},
+		{"NegativeWrongIssuer", rsaSigner, func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, jwt.ErrTokenInvalidIssuer},
+		{"NegativeWrongAudience", rsaSigner, func(c jwt.MapClaims) { c["aud"] = "web" }, jwt.ErrTokenInvalidAudience},
+		{"NegativeNotYetValid", ecSigner, func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }, jwt.ErrTokenNotValidYet},
+		{"NegativeExpired", edSigner, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, jwt.ErrTokenExpired},
+		{"NegativeUnknownKid", unknown, nil, ErrKeyNotFound},
+		{"NegativeAlgorithmConfusion", confused, nil, ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			claims := validClaims()
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			got, err := verifier.Verify(context.Background(), tt.signer.sign(t, claims))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got["iss"] != "https://idp.example" {
+				t.Errorf("got claims %v", got)
+			}
+		})
+	}
+
+	// Подпись чужим ключом с тем же kid
+	forged := newECSigner(t, "ec")
+	if _, err := verifier.Verify(context.Background(), forged.sign(t, validClaims())); !errors.Is(err, jwt.ErrTokenSignatureInvalid) {
+		t.Errorf("expected invalid signature, got %v", err)
+	}
+
+	// Без exp токен проходит проверку, только если exp не обязателен
+	noExp := validClaims()
+	delete(noExp, "exp")
+	if _, err := verifier.Verify(context.Background(), rsaSigner.sign(t, noExp)); err != nil {
+		t.Errorf("unexpected error for token without exp: %v", err)
+	}
+	strict := NewVerifier(keys, WithExpirationRequired())
+	if _, err := strict.Verify(context.Background(), rsaSigner.sign(t, noExp)); !errors.Is(err, jwt.ErrTokenRequiredClaimMissing) {
+		t.Errorf("expected missing exp error, got %v", err)
+	}
+}
+
+// TestRemoteKeySetRotation Ключи кешируются, а при ротации набор загружается заново по неизвестному kid
+func TestRemoteKeySetRotation(t *testing.T) {
+	oldSigner := newECSigner(t, "old")
+	newSigner := newEd25519Signer(t, "new")
+	var (
+		mu      sync.Mutex
+		current = jwksJSON(t, oldSigner)
+		fetches atomic.Int32
+	)
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(current)
+	}))
+	defer jwksServer.Close()
+
+	keys := NewRemoteKeySet(jwksServer.URL, nil)
+	keys.MinRefreshInterval = 0
+	verifier := NewVerifier(keys)
+
+	for i := 0; i < 3; i++ {
+		if _, err := verifier.Verify(context.Background(), oldSigner.sign(t, validClaims())); err != nil {
+			t.Fatalf("verify with old key: %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("got %d fetches, want 1", got)
+	}
+
+	mu.Lock()
+	current = jwksJSON(t, newSigner)
+	mu.Unlock()
+	if _, err := verifier.Verify(context.Background(), newSigner.sign(t, validClaims())); err != nil {
+		t.Fatalf("verify with rotated key: %v", err)
+	}
+	if got := fetches.Load(); got != 2 {
+		t.Errorf("got %d fetches, want 2", got)
+	}
+	if _, err := verifier.Verify(context.Background(), oldSigner.sign(t, validClaims())); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("expected removed key to be rejected, got %v", err)
+	}
+}
+
+func TestParseJWKSSkipsUnsupportedKeys(t *testing.T) {
+	signer := newECSigner(t, "ec")
+	tests := []struct {
+		testName string
+		extra    map[string]string
+		wantErr  bool
+	}{
+		{"PositiveSecp256k1", map[string]string{"kty": "EC", "kid": "k1", "crv": "secp256k1", "x": b64([]byte{1}), "y": b64([]byte{2})}, false},
+		{"PositiveX25519", map[string]string{"kty": "OKP", "kid": "x", "crv": "X25519", "x": b64(make([]byte, 32))}, false},
+		{"PositiveUnknownKty", map[string]string{"kty": "AKP", "kid": "pq"}, false},
+		{"NegativeMalformedEC", map[string]string{"kty": "EC", "kid": "bad", "crv": "P-256", "x": b64([]byte{1}), "y": b64([]byte{2})}, true},
+		{"NegativeMalformedRSA", map[string]string{"kty": "RSA", "kid": "bad", "n": "", "e": "AQAB"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			keys, err := ParseJWKS(jwksJSON(t, signer, testSigner{jwk: tt.extra}))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error for malformed key")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJWKS failed: %v", err)
+			}
+			if _, err := NewVerifier(keys).Verify(context.Background(), signer.sign(t, validClaims())); err != nil {
+				t.Errorf("Verify failed: %v", err)
+			}
+		})
+	}
+}
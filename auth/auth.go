@@ -1,57 +1,227 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/ShlykovPavel/JWTAuth/clock"
 	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
 	"github.com/ShlykovPavel/JWTAuth/scheduler"
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 )
 
+var errNoRefreshToken = errors.New("no refresh token")
+
+// DefaultExpirySkew - за сколько до истечения GetToken считает токен устаревшим и обновляет его
+const DefaultExpirySkew = 10 * time.Second
+
+// refreshScheduler планирует вызов handleRefresh перед истечением токена:
+// собственный scheduler.Scheduler клиента или запись в общей очереди Manager
+type refreshScheduler interface {
+	ScheduleRefreshLifetime(lifetime scheduler.Lifetime) time.Time
+	Stop()
+}
+
 type JWTAuth struct {
 	loginURL    string
 	refreshURL  string
 	credentials *requests.Credentials
-	retryCount  int
+	retryPolicy requests.RetryPolicy
+	codec       requests.TokenEndpointCodec
+	verifier    *JWTParser.Verifier
+	store       TokenStore
+	metrics     Metrics
+	tracer      trace.Tracer
+	refreshSkew time.Duration
+	expirySkew  time.Duration
+	clock       clock.Clock
 	logger      *slog.Logger
-	scheduler   *scheduler.Scheduler
+	scheduler   refreshScheduler
 	tokens      *requests.Tokens
 	httpClient  *http.Client
+	client      *requests.Client
 	mu          sync.RWMutex // Используем RWMutex для оптимизации чтения
+
+	state   State
+	stateMu sync.Mutex
+
+	// Задержки между попытками восстановления после неудачного refresh
+	recoveryMinBackoff time.Duration
+	recoveryMaxBackoff time.Duration
+
+	// refreshStrategy - стратегия из WithRefreshStrategy, а если она не задана - FixedSkew(refreshSkew)
+	refreshStrategy    scheduler.RefreshStrategy
+	minRefreshInterval time.Duration
+	maxRefreshInterval time.Duration
+
+	// ctx ограничивает время жизни клиента: отменяется в Stop или при отмене контекста StartContext
+	ctx    context.Context
+	cancel context.CancelFunc
+	// ready закрывается после первого успешного логина
+	ready     chan struct{}
+	readyOnce sync.Once
+	// started закрывается после первой попытки старта, startErr - её ошибка
+	started     chan struct{}
+	startedOnce sync.Once
+	startErr    error
+
+	// queue и queueKey задаёт Manager: обновления планируются в общей очереди вместо собственного планировщика
+	queue    *scheduler.Queue
+	queueKey string
+
+	// tracerProvider задаёт WithTracerProvider, nil - трассировка выключена
+	tracerProvider trace.TracerProvider
+
+	// renewing - текущее обновление токенов, nil если обновление не выполняется
+	renewing *renewCall
+	renewMu  sync.Mutex
+
+	// subs - подписчики на события, после Stop каналы закрыты и subsClosed выставлен
+	subs       map[*subscriber]struct{}
+	subsClosed bool
+	subsMu     sync.Mutex
+
+	// health - сведения для Status, обновляются по событиям
+	health health
 }
 
+// NewJwtAuth создаёт клиент с позиционными параметрами.
+// Оставлен для совместимости, новый код должен использовать New.
 func NewJwtAuth(loginURL, refreshURL, username, password string, retryCount int, logger *slog.Logger) *JWTAuth {
-	return &JWTAuth{
-		loginURL:    loginURL,
-		refreshURL:  refreshURL,
-		credentials: &requests.Credentials{Username: username, Password: password},
-		retryCount:  retryCount,
-		logger:      logger,
-		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	return newJWTAuth(
+		WithEndpoints(loginURL, refreshURL),
+		WithCredentials(username, password),
+		WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: retryCount + 1}),
+		WithLogger(logger),
+	)
+}
+
+// New создаёт клиент JWT аутентификации.
+// Обязателен только URL логина (WithEndpoints), остальные параметры имеют значения по умолчанию.
+func New(opts ...Option) (*JWTAuth, error) {
+	a := newJWTAuth(opts...)
+	if a.loginURL == "" {
+		return nil, errors.New("login URL is required")
 	}
+	return a, nil
+}
+
+func newJWTAuth(opts ...Option) *JWTAuth {
+	a := &JWTAuth{
+		credentials: &requests.Credentials{},
+		refreshSkew: scheduler.DefaultRefreshSkew,
+		expirySkew:  DefaultExpirySkew,
+		clock:       clock.System,
+		logger:      slog.Default(),
+
+		minRefreshInterval: scheduler.DefaultMinRefreshInterval,
+		recoveryMinBackoff: time.Second,
+		recoveryMaxBackoff: time.Minute,
+		ready:              make(chan struct{}),
+		started:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	a.client = requests.NewClient(a.httpClient,
+		requests.WithRetryPolicy(a.retryPolicy),
+		requests.WithCodec(a.codec),
+		requests.WithClock(a.clock),
+		requests.WithMetrics(a.metrics),
+		requests.WithTracerProvider(a.tracerProvider))
+	tracerProvider := a.tracerProvider
+	if tracerProvider == nil {
+		tracerProvider = noop.NewTracerProvider()
+	}
+	a.tracer = tracerProvider.Tracer(requests.TracerName)
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	if a.refreshStrategy == nil {
+		a.refreshStrategy = scheduler.FixedSkew(a.refreshSkew)
+	}
+	schedulerOpts := []scheduler.Option{
+		scheduler.WithRefreshStrategy(a.refreshStrategy),
+		scheduler.WithRefreshInterval(a.minRefreshInterval, a.maxRefreshInterval),
+		scheduler.WithClock(a.clock),
+	}
+	if a.queue != nil {
+		// Запись привязана к a.ctx: после Stop ленивый логин, завершившийся позже, не вернёт её в общую очередь
+		a.scheduler = a.queue.EntryContext(a.ctx, a.queueKey, a.handleRefresh, schedulerOpts...)
+	} else {
+		a.scheduler = scheduler.NewSchedulerContext(a.ctx, a.handleRefresh, a.logger, schedulerOpts...)
+	}
+	return a
 }
 
 func (a *JWTAuth) Start() error {
+	return a.StartContext(context.Background())
+}
+
+// StartContext выполняет первоначальный логин и запускает автоматическое обновление токенов.
+// ctx ограничивает не только логин, но и всё время работы клиента:
+// его отмена прерывает текущие запросы, ожидание между попытками и запланированные обновления, как и вызов Stop.
+func (a *JWTAuth) StartContext(ctx context.Context) error {
+	context.AfterFunc(ctx, a.Stop)
+	return a.start(ctx)
+}
+
+// start выполняет первоначальный логин и планирует обновление. ctx ограничивает только логин
+func (a *JWTAuth) start(ctx context.Context) (err error) {
+	ctx, span := a.tracer.Start(ctx, "JWTAuth.Start")
+	defer func() { endSpan(span, err) }()
+	// После неудачного старта GetTokenContext не ждёт логина, который никто не выполняет
+	defer a.startedOnce.Do(func() {
+		a.startErr = err
+		close(a.started)
+	})
+
+	// Токены из хранилища позволяют не выполнять логин при перезапуске
+	if tokens := a.loadTokens(ctx); tokens != nil {
+		span.SetAttributes(attribute.Bool("jwtauth.tokens_from_store", true))
+		a.mu.Lock()
+		a.tokens = tokens
+		a.mu.Unlock()
+		if !a.accessTokenFresh(tokens) {
+			if err := a.renew(ctx); err != nil {
+				a.mu.Lock()
+				a.tokens = nil
+				a.mu.Unlock()
+				return err
+			}
+			return nil
+		}
+		a.observeTokens(tokens, false)
+		a.readyOnce.Do(func() { close(a.ready) })
+		a.setState(StateAuthenticated)
+		return a.scheduleNextRefresh()
+	}
+
 	// Первоначальный логин
-	tokens, err := requests.LoginOrRefreshInService(
-		a.loginURL,
-		*a.credentials,
-		a.logger,
-		a.retryCount,
-	)
+	tokens, err := a.login(ctx)
 	if err != nil {
+		a.emit(Event{Type: EventLoginFailed, Err: err})
 		return err
 	}
+	a.saveTokens(ctx, tokens)
+	a.observeTokens(tokens, true)
 
+	// Подписчики узнают о логине, когда новый токен уже доступен через GetToken
 	a.mu.Lock()
 	a.tokens = tokens
 	a.mu.Unlock()
-
-	// Инициализация планировщика
-	a.scheduler = scheduler.NewScheduler(a.handleRefresh, a.logger)
+	a.readyOnce.Do(func() { close(a.ready) })
+	expiresAt, _ := accessTokenExpiry(tokens)
+	a.emit(Event{Type: EventLoggedIn, ExpiresAt: expiresAt})
+	a.setState(StateAuthenticated)
 
 	// Планируем обновление
 	if err := a.scheduleNextRefresh(); err != nil {
@@ -61,26 +231,201 @@ func (a *JWTAuth) Start() error {
 	return nil
 }
 
-func (a *JWTAuth) handleRefresh() {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// handleRefresh вызывается планировщиком перед истечением access токена.
+// Если ни refresh, ни повторный логин не удались, запускается восстановление:
+// попытки повторяются с экспоненциальной задержкой, пока одна не завершится успехом или не будет отменён ctx.
+func (a *JWTAuth) handleRefresh(ctx context.Context) {
+	const op = "auth.handleRefresh"
+	log := a.logger.With(slog.String("op", op))
 
-	newTokens, err := requests.LoginOrRefreshInService(
-		a.refreshURL,
-		*a.tokens,
-		a.logger,
-		a.retryCount,
-	)
+	ctx, span := a.tracer.Start(ctx, "JWTAuth.handleRefresh")
+	defer span.End()
+
+	a.setState(StateRefreshing)
+	backoff := a.recoveryMinBackoff
+	for {
+		err := a.renew(ctx)
+		if err == nil {
+			return
+		}
+		// Контекст отменяется при остановке клиента или когда обновление уже запланировано заново
+		if ctx.Err() != nil {
+			log.Debug("refresh canceled", "error", err)
+			return
+		}
+
+		a.setState(StateRecovering)
+		log.Error("failed to renew tokens, will retry", "error", err, "retry_in", backoff)
+		span.AddEvent("renew failed", trace.WithAttributes(
+			attribute.String("error", err.Error()),
+			attribute.String("jwtauth.retry_in", backoff.String())))
+		timer := a.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			log.Debug("recovery stopped")
+			return
+		}
+		backoff = min(backoff*2, a.recoveryMaxBackoff)
+	}
+}
+
+// Refresh принудительно обновляет токены.
+// Сначала используется refreshURL, если сервер отклонил refresh токен - выполняется повторный логин через loginURL.
+// После успешного обновления планируется следующее автоматическое обновление.
+func (a *JWTAuth) Refresh() error {
+	return a.RefreshContext(context.Background())
+}
+
+// RefreshContext аналог Refresh, учитывающий контекст.
+// Если обновление уже выполняется, новый запрос не отправляется: вызов ждёт результат текущего.
+func (a *JWTAuth) RefreshContext(ctx context.Context) error {
+	return a.renew(ctx)
+}
+
+// renewIfCurrent обновляет токены, только если staleToken всё ещё является текущим access токеном.
+// Если токен уже успел обновиться в другой горутине, возвращается актуальный токен без запроса к серверу.
+func (a *JWTAuth) renewIfCurrent(ctx context.Context, staleToken string) (string, error) {
+	if current, err := a.currentToken(); err == nil && current != staleToken {
+		return current, nil
+	}
+	if err := a.renew(ctx); err != nil {
+		return "", err
+	}
+	return a.currentToken()
+}
+
+// renewCall - выполняющееся обновление токенов, результат которого ждут все вызывающие
+type renewCall struct {
+	done chan struct{}
+	err  error
+}
+
+// renew обновляет токены: refresh с откатом на повторный логин.
+// Одновременно выполняется не больше одного обновления, остальные вызовы ждут его результат.
+// Запросы выполняются в контексте клиента без блокировки токенов, поэтому GetToken всё это время
+// возвращает текущий токен, а отмена ctx прерывает только ожидание этого вызова, но не само обновление.
+func (a *JWTAuth) renew(ctx context.Context) error {
+	a.renewMu.Lock()
+	call := a.renewing
+	if call == nil {
+		call = &renewCall{done: make(chan struct{})}
+		a.renewing = call
+		// Обновление переживает отмену ctx, но остаётся в трассе вызвавшего его
+		go a.runRenew(trace.ContextWithSpan(a.ctx, trace.SpanFromContext(ctx)), call)
+	}
+	a.renewMu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *JWTAuth) runRenew(ctx context.Context, call *renewCall) {
+	call.err = a.doRenew(ctx)
+
+	a.renewMu.Lock()
+	a.renewing = nil
+	a.renewMu.Unlock()
+	close(call.done)
+}
+
+// doRenew выполняет обновление. Блокировка токенов берётся только для чтения и замены пары
+func (a *JWTAuth) doRenew(ctx context.Context) (err error) {
+	const op = "auth.renew"
+	log := a.logger.With(slog.String("op", op))
+	ctx, span := a.tracer.Start(ctx, "JWTAuth.renew")
+	defer func() { endSpan(span, err) }()
+
+	a.mu.RLock()
+	tokens := a.tokens
+	a.mu.RUnlock()
+
+	var newTokens *requests.Tokens
+	err = ErrNotAuthenticated
+	switch {
+	case tokens == nil:
+	case a.refreshURL == "", tokens.RefreshToken == "":
+		// Например, client_credentials grant: refresh токена нет, токены получаются повторным логином
+		err = errNoRefreshToken
+	case a.refreshTokenExpired(tokens.RefreshToken):
+		err = requests.ErrRefreshTokenExpired
+	default:
+		newTokens, err = a.refresh(ctx, *tokens)
+	}
 	if err != nil {
-		a.logger.Error("refresh failed", "error", err)
-		return
+		if ctx.Err() != nil {
+			return err
+		}
+		if errors.Is(err, errNoRefreshToken) {
+			log.Debug("no refresh token, logging in again")
+		} else {
+			log.Warn("refresh failed, trying to login again", "error", err)
+			if tokens != nil {
+				a.emit(Event{Type: EventRefreshFailed, Err: err})
+			}
+		}
+		span.SetAttributes(attribute.Bool("jwtauth.fallback_login", true))
+		newTokens, err = a.login(ctx)
+		if err != nil {
+			log.Error("login failed", "error", err)
+			if ctx.Err() == nil {
+				a.emit(Event{Type: EventLoginFailed, Err: err})
+			}
+			return err
+		}
 	}
+	a.saveTokens(ctx, newTokens)
+	a.observeTokens(newTokens, true)
+
+	// Подписчики узнают об обновлении, когда новый токен уже доступен через GetToken
+	a.mu.Lock()
 	a.tokens = newTokens
+	a.mu.Unlock()
+	a.readyOnce.Do(func() { close(a.ready) })
+	expiresAt, _ := accessTokenExpiry(newTokens)
+	a.emit(Event{Type: EventRefreshed, ExpiresAt: expiresAt})
+	schedErr := a.scheduleNextRefresh()
+	a.setState(StateAuthenticated)
+
+	if schedErr != nil {
+		log.Error("failed to schedule next refresh", "error", schedErr)
+	}
+	return nil
+}
+
+// login получает токены по учётным данным и проверяет access токен
+func (a *JWTAuth) login(ctx context.Context) (*requests.Tokens, error) {
+	tokens, err := a.client.Login(ctx, a.loginURL, *a.credentials, a.logger)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, a.verify(ctx, tokens)
+}
+
+// refresh обновляет токены по refresh токену и проверяет новый access токен
+func (a *JWTAuth) refresh(ctx context.Context, tokens requests.Tokens) (*requests.Tokens, error) {
+	newTokens, err := a.client.Refresh(ctx, a.refreshURL, tokens, a.logger)
+	if err != nil {
+		return nil, err
+	}
+	return newTokens, a.verify(ctx, newTokens)
+}
 
-	// Планируем следующее обновление (без блокировки, так как мьютекс уже заблокирован)
-	if err := a.scheduleNextRefreshUnlocked(); err != nil {
-		a.logger.Error("failed to schedule next refresh", "error", err)
+// verify проверяет access токен, если задан WithVerifier
+func (a *JWTAuth) verify(ctx context.Context, tokens *requests.Tokens) error {
+	if a.verifier == nil {
+		return nil
+	}
+	if _, err := a.verifier.Verify(ctx, tokens.AccessToken); err != nil {
+		a.logger.Error("token verification failed", slog.String("op", "auth.verify"), "error", err)
+		return fmt.Errorf("%w: %w", ErrInvalidToken, err)
 	}
+	return nil
 }
 
 func (a *JWTAuth) scheduleNextRefresh() error {
@@ -92,6 +437,16 @@ func (a *JWTAuth) scheduleNextRefresh() error {
 
 // scheduleNextRefreshUnlocked - внутренний метод без блокировок
 func (a *JWTAuth) scheduleNextRefreshUnlocked() error {
+	// Срок действия из ответа сервера (expires_in) позволяет работать и с непрозрачными токенами
+	if !a.tokens.ExpiresAt.IsZero() {
+		lifetime := scheduler.Lifetime{ExpiresAt: a.tokens.ExpiresAt}
+		if a.tokens.ExpiresIn > 0 {
+			lifetime.IssuedAt = a.tokens.ExpiresAt.Add(-a.tokens.ExpiresIn)
+		}
+		a.refreshScheduled(lifetime)
+		return nil
+	}
+
 	claims, err := JWTParser.ParseUnverified(a.tokens.AccessToken, a.logger)
 	if err != nil {
 		return err
@@ -102,22 +457,169 @@ func (a *JWTAuth) scheduleNextRefreshUnlocked() error {
 		return err
 	}
 
-	a.scheduler.ScheduleRefresh(expiry)
+	lifetime := scheduler.Lifetime{ExpiresAt: expiry}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		lifetime.IssuedAt = iat.Time
+	}
+	a.refreshScheduled(lifetime)
 	return nil
 }
 
+// refreshScheduled планирует обновление и сообщает о нём подписчикам
+func (a *JWTAuth) refreshScheduled(lifetime scheduler.Lifetime) {
+	if refreshAt := a.scheduler.ScheduleRefreshLifetime(lifetime); !refreshAt.IsZero() {
+		a.emit(Event{Type: EventRefreshScheduled, RefreshAt: refreshAt, ExpiresAt: lifetime.ExpiresAt})
+	}
+}
+
+// GetToken возвращает действующий access токен.
+// Если токен истёк или истекает в ближайшие expirySkew (например, после неудачного refresh или сна ноутбука),
+// перед возвратом синхронно выполняется refresh или повторный логин. Если получить новый токен не удалось,
+// возвращается ErrTokenExpired, а если клиент ещё не запущен - ErrNotAuthenticated.
 func (a *JWTAuth) GetToken() (string, error) {
+	return a.freshToken(context.Background())
+}
+
+// freshToken возвращает текущий access токен, обновляя его, если он истёк
+func (a *JWTAuth) freshToken(ctx context.Context) (string, error) {
+	a.mu.RLock()
+	tokens := a.tokens
+	a.mu.RUnlock()
+
+	if tokens == nil {
+		return "", ErrNotAuthenticated
+	}
+	if !a.accessTokenExpired(tokens) {
+		return tokens.AccessToken, nil
+	}
+
+	a.logger.Warn("access token expired, renewing synchronously", slog.String("op", "auth.GetToken"))
+	if err := a.renew(ctx); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrTokenExpired, err)
+	}
+	a.mu.RLock()
+	tokens = a.tokens
+	a.mu.RUnlock()
+	if a.accessTokenExpired(tokens) {
+		return "", ErrTokenExpired
+	}
+	return tokens.AccessToken, nil
+}
+
+// currentToken возвращает текущий access токен без проверки срока действия
+func (a *JWTAuth) currentToken() (string, error) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
 	if a.tokens == nil {
-		return "", errors.New("not authenticated")
+		return "", ErrNotAuthenticated
 	}
 	return a.tokens.AccessToken, nil
 }
 
+// CurrentClaims возвращает claims текущего access токена: subject, роли, тенант, срок действия.
+// Подпись не проверяется, токен получен от настроенного сервера (для проверки используйте WithVerifier).
+// Для непрозрачных (не JWT) токенов возвращает ошибку разбора.
+func (a *JWTAuth) CurrentClaims() (*JWTParser.Claims, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.tokens == nil {
+		return nil, ErrNotAuthenticated
+	}
+	return JWTParser.Parse[*JWTParser.Claims](a.tokens.AccessToken, a.logger)
+}
+
+// GetTokenContext возвращает текущий access токен.
+// Если первый логин ещё не завершён, ждёт его, пока не будет отменён ctx или не будет вызван Stop.
+// Если Start уже завершился ошибкой, сразу возвращает ErrNotAuthenticated с причиной.
+func (a *JWTAuth) GetTokenContext(ctx context.Context) (string, error) {
+	select {
+	case <-a.ready:
+	case <-a.started:
+		select {
+		case <-a.ready:
+		default:
+			return "", fmt.Errorf("%w: %w", ErrNotAuthenticated, a.startErr)
+		}
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case <-a.ctx.Done():
+		return "", ErrStopped
+	}
+	return a.freshToken(ctx)
+}
+
 func (a *JWTAuth) Stop() {
-	if a.scheduler != nil {
-		a.scheduler.Stop()
+	a.cancel()
+	a.scheduler.Stop()
+	a.setState(StateStopped)
+	a.closeSubscribers()
+}
+
+// accessTokenExpired сообщает, что access токен истёк или истекает в ближайшие expirySkew.
+// Токен без известного срока действия считается действующим.
+func (a *JWTAuth) accessTokenExpired(tokens *requests.Tokens) bool {
+	if !tokens.ExpiresAt.IsZero() {
+		return !tokens.ExpiresAt.After(a.clock.Now().Add(a.expirySkew))
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokens.AccessToken, claims); err != nil {
+		return false
+	}
+	return JWTParser.IsTokenExpiredAt(claims, a.clock.Now().Add(a.expirySkew))
+}
+
+// accessTokenExpiry возвращает срок действия access токена: из ответа сервера (expires_in) или из claim exp
+func accessTokenExpiry(tokens *requests.Tokens) (time.Time, bool) {
+	lifetime, ok := accessTokenLifetime(tokens)
+	return lifetime.ExpiresAt, ok
+}
+
+// accessTokenLifetime возвращает срок действия access токена так же, как его видит планировщик:
+// из ответа сервера (expires_in) или из claims exp и iat
+func accessTokenLifetime(tokens *requests.Tokens) (scheduler.Lifetime, bool) {
+	if !tokens.ExpiresAt.IsZero() {
+		lifetime := scheduler.Lifetime{ExpiresAt: tokens.ExpiresAt}
+		if tokens.ExpiresIn > 0 {
+			lifetime.IssuedAt = tokens.ExpiresAt.Add(-tokens.ExpiresIn)
+		}
+		return lifetime, true
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokens.AccessToken, claims); err != nil {
+		return scheduler.Lifetime{}, false
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return scheduler.Lifetime{}, false
+	}
+	lifetime := scheduler.Lifetime{ExpiresAt: exp.Time}
+	if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+		lifetime.IssuedAt = iat.Time
+	}
+	return lifetime, true
+}
+
+// refreshTokenExpired сообщает, что refresh токен является JWT с истёкшим exp.
+// Непрозрачные (не JWT) refresh токены считаются действительными - решение о них принимает сервер.
+func (a *JWTAuth) refreshTokenExpired(refreshToken string) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(refreshToken, claims); err != nil {
+		return false
+	}
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		return false
+	}
+	return exp.Before(a.clock.Now())
+}
+
+// endSpan завершает span, отмечая ошибку
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
+	span.End()
 }
@@ -0,0 +1,854 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/ShlykovPavel/JWTAuth/authtest"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/ShlykovPavel/JWTAuth/scheduler"
+	"github.com/ShlykovPavel/JWTAuth/tokenstore"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForState ждёт, пока клиент перейдёт в нужное состояние
+func waitForState(t *testing.T, a *JWTAuth, want State) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for a.State() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("state is %s, want %s", a.State(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleRefreshFallsBackToLogin(t *testing.T) {
+	tests := []struct {
+		testName      string
+		refreshStatus int
+		loginFailures int32
+		wantLogins    int32
+	}{
+		{"PositiveRefresh", http.StatusOK, 0, 1},
+		{"PositiveLoginAfterRejectedRefresh", http.StatusUnauthorized, 0, 2},
+		{"PositiveRecoveryAfterFailedLogins", http.StatusUnauthorized, 3, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			idp, logins, _ := newTestIdentityServer(t, tt.refreshStatus, tt.loginFailures)
+			jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+			jwtauth.recoveryMinBackoff = time.Millisecond
+			jwtauth.recoveryMaxBackoff = 5 * time.Millisecond
+			if err := jwtauth.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			defer jwtauth.Stop()
+			firstToken, _ := jwtauth.GetToken()
+
+			jwtauth.handleRefresh(jwtauth.ctx)
+
+			if jwtauth.State() != StateAuthenticated {
+				t.Errorf("state is %s, want %s", jwtauth.State(), StateAuthenticated)
+			}
+			if got := logins.Load(); got != tt.wantLogins {
+				t.Errorf("got %d logins, want %d", got, tt.wantLogins)
+			}
+			if token, _ := jwtauth.GetToken(); token == firstToken {
+				t.Error("token was not renewed")
+			}
+		})
+	}
+}
+
+func TestStopInterruptsRecovery(t *testing.T) {
+	idp, _, _ := newTestIdentityServer(t, http.StatusUnauthorized, 1000)
+	jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+	jwtauth.recoveryMinBackoff = time.Millisecond
+	jwtauth.recoveryMaxBackoff = 5 * time.Millisecond
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		jwtauth.handleRefresh(jwtauth.ctx)
+		close(finished)
+	}()
+	waitForState(t, jwtauth, StateRecovering)
+	jwtauth.Stop()
+
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("recovery was not stopped")
+	}
+	if jwtauth.State() != StateStopped {
+		t.Errorf("state is %s, want %s", jwtauth.State(), StateStopped)
+	}
+}
+
+func TestStartContextCancel(t *testing.T) {
+	idp, _, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := jwtauth.StartContext(ctx); err != nil {
+		t.Fatalf("StartContext failed: %v", err)
+	}
+	if _, err := jwtauth.GetTokenContext(ctx); err != nil {
+		t.Fatalf("GetTokenContext failed: %v", err)
+	}
+
+	cancel()
+	waitForState(t, jwtauth, StateStopped)
+	if jwtauth.ctx.Err() == nil {
+		t.Error("client context must be canceled together with start context")
+	}
+}
+
+func TestGetTokenContextWaitsForLogin(t *testing.T) {
+	jwtauth := NewJwtAuth("http://127.0.0.1:0/login", "http://127.0.0.1:0/refresh", "user", "password", 0, newTestLogger())
+	defer jwtauth.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := jwtauth.GetTokenContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+}
+
+// countingTransport считает запросы, прошедшие через http.Client
+type countingTransport struct {
+	calls atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(WithCredentials("user", "password")); err == nil {
+		t.Error("expected error without login URL")
+	}
+
+	idp, logins, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	transport := &countingTransport{}
+	jwtauth, err := New(
+		WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"),
+		WithCredentials("user", "password"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithRetryPolicy(requests.LinearRetry{Count: 1}),
+		WithLogger(newTestLogger()),
+		WithRefreshSkew(30*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer jwtauth.Stop()
+	if err := jwtauth.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	if got := transport.calls.Load(); got != 2 {
+		t.Errorf("injected http client got %d calls, want 2", got)
+	}
+	if got := logins.Load(); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	var grants atomic.Int32
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		n := grants.Add(1)
+		fmt.Fprintf(w, `{"access_token": "opaque-%d", "token_type": "Bearer", "expires_in": 3600}`, n)
+	}))
+	defer idp.Close()
+
+	jwtauth, err := New(
+		WithClientCredentials(idp.URL, "client", "secret", requests.ClientCredentialsCodec{Scopes: []string{"api"}}),
+		WithLogger(newTestLogger()),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer jwtauth.Stop()
+
+	// Без refresh токена обновление выполняется повторным grant
+	if err := jwtauth.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	token, _ := jwtauth.GetToken()
+	if token != "opaque-2" {
+		t.Errorf("got token %q, want %q", token, "opaque-2")
+	}
+	if got := grants.Load(); got != 2 {
+		t.Errorf("got %d grants, want 2", got)
+	}
+}
+
+// TestWithVerifier Токен с неверной подписью не принимается ни при логине, ни при refresh
+func TestWithVerifier(t *testing.T) {
+	idp, _, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	tests := []struct {
+		testName string
+		secret   string
+		wantErr  error
+	}{
+		{"PositiveValidSignature", "test-secret", nil},
+		{"NegativeWrongKey", "other-secret", ErrInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			keys := JWTParser.NewStaticKeySet(JWTParser.Key{Algorithm: "HS256", Key: []byte(tt.secret)})
+			jwtauth, err := New(
+				WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"),
+				WithCredentials("user", "password"),
+				WithLogger(newTestLogger()),
+				WithVerifier(JWTParser.NewVerifier(keys)),
+			)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			defer jwtauth.Stop()
+			err = jwtauth.Start()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != nil {
+				if _, err := jwtauth.GetToken(); !errors.Is(err, ErrNotAuthenticated) {
+					t.Errorf("rejected token must not be stored, got %v", err)
+				}
+				return
+			}
+			if err := jwtauth.Refresh(); err != nil {
+				t.Errorf("Refresh failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestCurrentClaims(t *testing.T) {
+	idp, _, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+	defer jwtauth.Stop()
+	if _, err := jwtauth.CurrentClaims(); !errors.Is(err, ErrNotAuthenticated) {
+		t.Fatalf("expected ErrNotAuthenticated before Start, got %v", err)
+	}
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	claims, err := jwtauth.CurrentClaims()
+	if err != nil {
+		t.Fatalf("CurrentClaims failed: %v", err)
+	}
+	if claims.Subject != "login-1" {
+		t.Errorf("got subject %q, want login-1", claims.Subject)
+	}
+	if until := time.Until(claims.ExpiresAt.Time); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("got expiry in %v, want about an hour", until)
+	}
+}
+
+// TestWithTokenStore Перезапуск берёт токены из хранилища вместо логина
+func TestWithTokenStore(t *testing.T) {
+	idp, logins, refreshes := newTestIdentityServer(t, http.StatusOK, 0)
+	store := tokenstore.NewMemory()
+	newAuth := func() *JWTAuth {
+		jwtauth, err := New(
+			WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"),
+			WithCredentials("user", "password"),
+			WithLogger(newTestLogger()),
+			WithTokenStore(store),
+		)
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		if err := jwtauth.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		return jwtauth
+	}
+
+	first := newAuth()
+	token, _ := first.GetToken()
+	first.Stop()
+
+	second := newAuth()
+	defer second.Stop()
+	if got, _ := second.GetToken(); got != token {
+		t.Errorf("got token %q, want token from store", got)
+	}
+	if logins.Load() != 1 || refreshes.Load() != 0 {
+		t.Errorf("got %d logins and %d refreshes, want 1 and 0", logins.Load(), refreshes.Load())
+	}
+
+	// Истёкший access токен из хранилища обновляется по refresh токену, новая пара сохраняется
+	key := second.storeKey()
+	store.Save(context.Background(), key, requests.Tokens{AccessToken: signTestToken(t, "expired", -time.Minute), RefreshToken: "refresh"})
+	third := newAuth()
+	defer third.Stop()
+	if logins.Load() != 1 || refreshes.Load() != 1 {
+		t.Errorf("got %d logins and %d refreshes, want 1 and 1", logins.Load(), refreshes.Load())
+	}
+	saved, _ := store.Load(context.Background(), key)
+	if current, _ := third.GetToken(); saved == nil || saved.AccessToken != current {
+		t.Error("refreshed tokens were not saved")
+	}
+}
+
+// TestStoredTokensUseRefreshStrategy Токен из хранилища считается свежим по той же стратегии, что планирует обновления
+func TestStoredTokensUseRefreshStrategy(t *testing.T) {
+	tests := []struct {
+		testName    string
+		strategy    scheduler.RefreshStrategy
+		issuedAgo   time.Duration
+		ttl         time.Duration
+		wantRefresh int32
+	}{
+		{"PositiveFreshBySmallSkew", scheduler.FixedSkew(10 * time.Second), 0, 30 * time.Second, 0},
+		{"PositiveFreshByLifetimeFraction", scheduler.LifetimeFraction(0.8), 10 * time.Minute, time.Hour, 0},
+		{"NegativeStaleByLargeSkew", scheduler.FixedSkew(20 * time.Minute), 0, 10 * time.Minute, 1},
+		{"NegativeStaleByLifetimeFraction", scheduler.LifetimeFraction(0.5), 50 * time.Minute, time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			idp, logins, refreshes := newTestIdentityServer(t, http.StatusOK, 0)
+			store := tokenstore.NewMemory()
+			jwtauth, err := New(
+				WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"),
+				WithCredentials("user", "password"),
+				WithLogger(newTestLogger()),
+				WithTokenStore(store),
+				WithRefreshStrategy(tt.strategy),
+			)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			defer jwtauth.Stop()
+			stored := signTestTokenAt(t, "stored", time.Now().Add(-tt.issuedAgo), tt.ttl)
+			store.Save(context.Background(), jwtauth.storeKey(), requests.Tokens{AccessToken: stored, RefreshToken: "refresh"})
+
+			if err := jwtauth.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			if logins.Load() != 0 || refreshes.Load() != tt.wantRefresh {
+				t.Errorf("got %d logins and %d refreshes, want 0 and %d", logins.Load(), refreshes.Load(), tt.wantRefresh)
+			}
+		})
+	}
+}
+
+// TestRenewSingleFlight Одновременные обновления выполняют один запрос, а GetToken не ждёт его завершения
+func TestRenewSingleFlight(t *testing.T) {
+	var logins, refreshes atomic.Int32
+	release := make(chan struct{})
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var subject string
+		switch r.URL.Path {
+		case "/login":
+			subject = fmt.Sprintf("login-%d", logins.Add(1))
+		case "/refresh":
+			subject = fmt.Sprintf("refresh-%d", refreshes.Add(1))
+			<-release
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken":  signTestToken(t, subject, time.Hour),
+			"refreshToken": "refresh",
+		})
+	}))
+	defer idp.Close()
+
+	jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+	defer jwtauth.Stop()
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	oldToken, _ := jwtauth.GetToken()
+
+	// Явные Refresh и повторы после 401 одновременно
+	var wg sync.WaitGroup
+	errs := make(chan error, 6)
+	for range 3 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			errs <- jwtauth.Refresh()
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := jwtauth.renewIfCurrent(context.Background(), oldToken)
+			errs <- err
+		}()
+	}
+
+	for refreshes.Load() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	started := time.Now()
+	if token, err := jwtauth.GetToken(); err != nil || token != oldToken {
+		t.Errorf("got %q, %v while refresh is pending, want current token", token, err)
+	}
+	if elapsed := time.Since(started); elapsed > 50*time.Millisecond {
+		t.Errorf("GetToken blocked for %v during refresh", elapsed)
+	}
+	// Отмена контекста прерывает только ожидание вызывающего
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := jwtauth.RefreshContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("renew failed: %v", err)
+		}
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("got %d refresh calls, want 1", got)
+	}
+	if token, _ := jwtauth.GetToken(); token == oldToken {
+		t.Error("token was not renewed")
+	}
+}
+
+// TestGetTokenRenewsExpired GetToken не возвращает истёкший токен: обновляет его синхронно или возвращает ErrTokenExpired
+func TestGetTokenRenewsExpired(t *testing.T) {
+	var (
+		ttl       atomic.Int64
+		failing   atomic.Bool
+		refreshes atomic.Int32
+	)
+	ttl.Store(int64(5 * time.Second))
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/refresh" {
+			refreshes.Add(1)
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken":  signTestToken(t, r.URL.Path, time.Duration(ttl.Load())),
+			"refreshToken": "refresh",
+		})
+	}))
+	defer idp.Close()
+
+	jwtauth, err := New(
+		WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"),
+		WithCredentials("user", "password"),
+		WithLogger(newTestLogger()),
+		WithExpirySkew(30*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	// Токен живёт 5 секунд, а допуск 30 секунд: перед возвратом выполняется refresh
+	ttl.Store(int64(time.Hour))
+	token, err := jwtauth.GetToken()
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	claims, _ := JWTParser.Parse[*JWTParser.Claims](token, newTestLogger())
+	if claims.Subject != "/refresh" || refreshes.Load() != 1 {
+		t.Errorf("got token for %q after %d refreshes, want refreshed token", claims.Subject, refreshes.Load())
+	}
+	// Действующий токен возвращается без запросов
+	if _, err := jwtauth.GetToken(); err != nil || refreshes.Load() != 1 {
+		t.Errorf("got %v after %d refreshes, want cached token", err, refreshes.Load())
+	}
+
+	// Обновить токен невозможно: ошибка типизирована, истёкший токен не возвращается
+	jwtauth.mu.Lock()
+	jwtauth.tokens.AccessToken = signTestToken(t, "expired", -time.Minute)
+	jwtauth.mu.Unlock()
+	failing.Store(true)
+	if token, err := jwtauth.GetToken(); !errors.Is(err, ErrTokenExpired) || !errors.Is(err, requests.ErrInvalidCredentials) {
+		t.Errorf("got %q, %v, want ErrTokenExpired", token, err)
+	}
+}
+
+func TestRefreshCycleWithFakeClock(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	var (
+		failing   atomic.Bool
+		refreshes atomic.Int32
+	)
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/refresh" {
+			refreshes.Add(1)
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken":  signTestTokenAt(t, r.URL.Path, fake.Now(), time.Hour),
+			"refreshToken": "refresh",
+		})
+	}))
+	defer idp.Close()
+
+	jwtauth, err := New(
+		WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"),
+		WithLogger(newTestLogger()),
+		WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 1}),
+		WithClock(fake),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// advance ждёт, пока клиент заведёт таймер, и переводит часы
+	advance := func(d time.Duration) {
+		t.Helper()
+		if err := fake.BlockUntil(ctx, 1); err != nil {
+			t.Fatalf("timer was not scheduled: %v", err)
+		}
+		fake.Advance(d)
+	}
+
+	// Обновление за минуту до истечения часового токена
+	advance(59*time.Minute - time.Second)
+	if refreshes.Load() != 0 {
+		t.Fatal("refresh happened before its time")
+	}
+	advance(time.Second)
+	for refreshes.Load() != 1 {
+		if ctx.Err() != nil {
+			t.Fatalf("got %d refreshes, want 1", refreshes.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Неудачное обновление: следующая попытка через recoveryMinBackoff по тем же часам
+	failing.Store(true)
+	advance(59 * time.Minute)
+	waitForState(t, jwtauth, StateRecovering)
+	failing.Store(false)
+	advance(jwtauth.recoveryMinBackoff)
+	waitForState(t, jwtauth, StateAuthenticated)
+	if got := refreshes.Load(); got != 2 {
+		t.Errorf("got %d refreshes, want 2", got)
+	}
+	if _, err := jwtauth.GetToken(); err != nil {
+		t.Errorf("GetToken failed: %v", err)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	provider := authtest.NewProvider(authtest.WithCredentials("user", "secret-password"))
+	defer provider.Close()
+
+	jwtauth, err := New(
+		WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+		WithCredentials("user", "secret-password"),
+		WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 2, InitialInterval: time.Millisecond}),
+		WithLogger(newTestLogger()),
+		WithTracerProvider(tp),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+
+	provider.FailNext(authtest.Login, authtest.ServerError)
+	ctx, root := tp.Tracer("test").Start(context.Background(), "test")
+	if err := jwtauth.StartContext(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	provider.FailNext(authtest.Refresh, authtest.Unauthorized)
+	if err := jwtauth.RefreshContext(ctx); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	root.End()
+	token, _ := jwtauth.GetToken()
+
+	spans := exporter.GetSpans()
+	byID := make(map[trace.SpanID]tracetest.SpanStub)
+	for _, span := range spans {
+		byID[span.SpanContext.SpanID()] = span
+	}
+	// path возвращает цепочку имён от span до корня трассы
+	path := func(span tracetest.SpanStub) string {
+		names := []string{span.Name}
+		for parent, ok := byID[span.Parent.SpanID()]; ok; parent, ok = byID[parent.Parent.SpanID()] {
+			names = append(names, parent.Name)
+		}
+		return strings.Join(names, " < ")
+	}
+	var paths []string
+	for _, span := range spans {
+		if span.SpanContext.TraceID() != root.SpanContext().TraceID() {
+			t.Errorf("span %q is not in the caller trace", span.Name)
+		}
+		paths = append(paths, path(span))
+		for _, attr := range span.Attributes {
+			if value := attr.Value.Emit(); strings.Contains(value, "secret-password") || strings.Contains(value, token) {
+				t.Errorf("span %q attribute %s leaks a secret", span.Name, attr.Key)
+			}
+		}
+	}
+	wantPaths := []string{
+		"POST < jwtauth.login < JWTAuth.Start < test",
+		"POST < jwtauth.login < JWTAuth.Start < test",
+		"jwtauth.login < JWTAuth.Start < test",
+		"JWTAuth.Start < test",
+		"POST < jwtauth.refresh < JWTAuth.renew < test",
+		"jwtauth.refresh < JWTAuth.renew < test",
+		"POST < jwtauth.login < JWTAuth.renew < test",
+		"jwtauth.login < JWTAuth.renew < test",
+		"JWTAuth.renew < test",
+		"test",
+	}
+	if strings.Join(paths, "\n") != strings.Join(wantPaths, "\n") {
+		t.Errorf("got spans\n%s\nwant\n%s", strings.Join(paths, "\n"), strings.Join(wantPaths, "\n"))
+	}
+
+	// Каждая попытка передаёт серверу свой span в traceparent
+	calls := provider.Calls()
+	var attempts []tracetest.SpanStub
+	for _, span := range spans {
+		if span.Name == "POST" {
+			attempts = append(attempts, span)
+		}
+	}
+	if len(calls) != len(attempts) {
+		t.Fatalf("got %d calls and %d attempt spans", len(calls), len(attempts))
+	}
+	for i, call := range calls {
+		want := fmt.Sprintf("00-%s-%s-01", attempts[i].SpanContext.TraceID(), attempts[i].SpanContext.SpanID())
+		if got := call.Header.Get("traceparent"); got != want {
+			t.Errorf("call %d: got traceparent %q, want %q", i, got, want)
+		}
+	}
+	if attempts[0].Status.Code != codes.Error {
+		t.Errorf("failed attempt has status %v, want error", attempts[0].Status.Code)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	provider := authtest.NewProvider()
+	defer provider.Close()
+
+	jwtauth, err := New(
+		WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+		WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 1}),
+		WithLogger(newTestLogger()),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	events, _ := jwtauth.Subscribe()
+	received := make(chan []Event)
+	go func() {
+		var all []Event
+		for event := range events {
+			all = append(all, event)
+		}
+		received <- all
+	}()
+	// Подписчик, который никогда не читает канал, не должен мешать обновлению
+	stalled, _ := jwtauth.Subscribe()
+	transitions := make(chan string, 10)
+	jwtauth.OnStateChange(func(from, to State) {
+		transitions <- from.String() + "->" + to.String()
+	})
+	loginErrs := make(chan error, 10)
+	jwtauth.OnLoginFailed(func(err error) {
+		loginErrs <- err
+	})
+
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	provider.FailNext(authtest.Refresh, authtest.Unauthorized)
+	if err := jwtauth.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	provider.FailNext(authtest.Refresh, authtest.Unauthorized)
+	provider.FailNext(authtest.Login, authtest.Unauthorized)
+	if err := jwtauth.Refresh(); err == nil {
+		t.Fatal("expected Refresh error")
+	}
+	for range EventBufferSize {
+		if err := jwtauth.Refresh(); err != nil {
+			t.Fatalf("Refresh failed: %v", err)
+		}
+	}
+	jwtauth.Stop()
+
+	var got []string
+	for _, event := range <-received {
+		got = append(got, event.Type.String())
+		switch event.Type {
+		case EventLoggedIn, EventRefreshed:
+			if event.ExpiresAt.IsZero() {
+				t.Errorf("%s event without expiry", event.Type)
+			}
+		case EventRefreshScheduled:
+			if !event.RefreshAt.Before(event.ExpiresAt) {
+				t.Errorf("refresh at %v is not before expiry %v", event.RefreshAt, event.ExpiresAt)
+			}
+		case EventLoginFailed, EventRefreshFailed:
+			if !errors.Is(event.Err, requests.ErrInvalidCredentials) && !errors.Is(event.Err, requests.ErrRefreshTokenExpired) {
+				t.Errorf("%s event with error %v", event.Type, event.Err)
+			}
+		}
+	}
+	want := []string{
+		"logged_in", "state_changed", "refresh_scheduled",
+		"refresh_failed", "refreshed", "refresh_scheduled",
+		"refresh_failed", "login_failed",
+	}
+	if len(got) < len(want) || strings.Join(got[:len(want)], ",") != strings.Join(want, ",") {
+		t.Fatalf("got events %v, want prefix %v", got, want)
+	}
+	if got[len(got)-1] != "state_changed" {
+		t.Errorf("got last event %s, want state change to stopped", got[len(got)-1])
+	}
+
+	// Буфер медленного подписчика заполнен, лишние события отброшены, канал закрыт в Stop
+	var buffered int
+	for range stalled {
+		buffered++
+	}
+	if buffered != EventBufferSize {
+		t.Errorf("got %d buffered events, want %d", buffered, EventBufferSize)
+	}
+
+	for _, want := range []string{"idle->authenticated", "authenticated->stopped"} {
+		select {
+		case got := <-transitions:
+			if got != want {
+				t.Errorf("got transition %s, want %s", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("OnStateChange was not called for %s", want)
+		}
+	}
+	select {
+	case err := <-loginErrs:
+		if !errors.Is(err, requests.ErrInvalidCredentials) {
+			t.Errorf("got login error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnLoginFailed was not called")
+	}
+
+	late, _ := jwtauth.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("subscription after Stop must return a closed channel")
+	}
+}
+
+func TestEventsSeeNewToken(t *testing.T) {
+	provider := authtest.NewProvider()
+	defer provider.Close()
+
+	jwtauth, err := New(
+		WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+		WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 1}),
+		WithLogger(newTestLogger()),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+
+	// Подписчик запрашивает токен прямо в обработчике события и должен получить уже новый
+	type result struct {
+		event EventType
+		token string
+		err   error
+	}
+	received := make(chan EventType, 2)
+	results := make(chan result, 2)
+	events, _ := jwtauth.Subscribe()
+	go func() {
+		for event := range events {
+			if event.Type == EventLoggedIn || event.Type == EventRefreshed {
+				received <- event.Type
+				token, err := jwtauth.GetToken()
+				results <- result{event.Type, token, err}
+			}
+		}
+	}()
+	check := func(want EventType) {
+		t.Helper()
+		select {
+		case got := <-results:
+			<-received
+			current, _ := jwtauth.GetToken()
+			if got.event != want || got.err != nil || got.token != current {
+				t.Errorf("got %s event with token error %v, stale token %t", got.event, got.err, got.token != current)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s event was not received", want)
+		}
+	}
+
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	check(EventLoggedIn)
+
+	// Пока пара токенов не может быть заменена, событие об обновлении не должно прийти
+	jwtauth.mu.RLock()
+	refreshed := make(chan error)
+	go func() { refreshed <- jwtauth.Refresh() }()
+	select {
+	case got := <-received:
+		jwtauth.mu.RUnlock()
+		t.Fatalf("got %s event before the new token was stored", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+	jwtauth.mu.RUnlock()
+	if err := <-refreshed; err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	check(EventRefreshed)
+}
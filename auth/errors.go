@@ -0,0 +1,20 @@
+package auth
+
+import "errors"
+
+var (
+	// ErrNotAuthenticated клиент ещё не получил токены: Start не вызывался или первый логин не удался
+	ErrNotAuthenticated = errors.New("not authenticated")
+	// ErrStopped клиент остановлен вызовом Stop или отменой контекста StartContext
+	ErrStopped = errors.New("auth stopped")
+	// ErrTokenExpired токен истёк, а обновить его не удалось
+	ErrTokenExpired = errors.New("token expired")
+	// ErrRefreshStalled запланированное обновление токенов опаздывает: сервис авторизации недоступен дольше допустимого
+	ErrRefreshStalled = errors.New("token refresh stalled")
+	// ErrInvalidToken сервер выдал токен, не прошедший проверку Verifier (подпись, iss, aud, nbf, exp)
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnknownIdentity в Manager нет учётной записи с таким именем
+	ErrUnknownIdentity = errors.New("unknown identity")
+	// ErrIdentityExists учётная запись с таким именем уже добавлена в Manager
+	ErrIdentityExists = errors.New("identity already exists")
+)
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"log/slog"
+	"time"
+)
+
+// EventBufferSize - размер буфера канала, возвращаемого Subscribe
+const EventBufferSize = 64
+
+// EventType - вид события жизненного цикла токенов
+type EventType int
+
+const (
+	// EventStateChanged состояние клиента изменилось (From, To)
+	EventStateChanged EventType = iota
+	// EventLoggedIn Start получил токены первоначальным логином (ExpiresAt)
+	EventLoggedIn
+	// EventRefreshed токены обновлены по refresh токену или повторным логином (ExpiresAt)
+	EventRefreshed
+	// EventLoginFailed логин при старте или после отказа refresh не удался (Err)
+	EventLoginFailed
+	// EventRefreshFailed запрос обновления по refresh токену не удался, клиент пробует повторный логин (Err)
+	EventRefreshFailed
+	// EventRefreshScheduled планировщик запланировал следующее обновление (RefreshAt, ExpiresAt)
+	EventRefreshScheduled
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventStateChanged:
+		return "state_changed"
+	case EventLoggedIn:
+		return "logged_in"
+	case EventRefreshed:
+		return "refreshed"
+	case EventLoginFailed:
+		return "login_failed"
+	case EventRefreshFailed:
+		return "refresh_failed"
+	case EventRefreshScheduled:
+		return "refresh_scheduled"
+	default:
+		return "unknown"
+	}
+}
+
+// Event - событие жизненного цикла токенов. Заполнены только поля, относящиеся к Type.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// From и To - состояния до и после перехода
+	From State
+	To   State
+	// ExpiresAt - срок действия access токена, нулевой если неизвестен
+	ExpiresAt time.Time
+	// RefreshAt - момент запланированного обновления
+	RefreshAt time.Time
+	// Err - причина неудачи
+	Err error
+}
+
+// subscriber - канал подписчика, закрывается при отписке или в Stop
+type subscriber struct {
+	ch chan Event
+}
+
+// Subscribe возвращает канал событий жизненного цикла токенов и функцию отписки.
+// События отправляются без ожидания: если подписчик не успевает читать и буфер канала (EventBufferSize) заполнен,
+// событие для него отбрасывается, поэтому медленный подписчик не задерживает обновление токенов.
+// Канал закрывается функцией отписки или вызовом Stop. Чтобы получить события Start, подписывайтесь до его вызова.
+// К моменту отправки EventLoggedIn и EventRefreshed новый токен уже возвращается GetToken.
+func (a *JWTAuth) Subscribe() (<-chan Event, func()) {
+	sub := &subscriber{ch: make(chan Event, EventBufferSize)}
+
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	if a.subsClosed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	if a.subs == nil {
+		a.subs = make(map[*subscriber]struct{})
+	}
+	a.subs[sub] = struct{}{}
+
+	return sub.ch, func() { a.unsubscribe(sub) }
+}
+
+func (a *JWTAuth) unsubscribe(sub *subscriber) {
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+
+	if _, ok := a.subs[sub]; ok {
+		delete(a.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// closeSubscribers закрывает каналы всех подписчиков, новые подписки получают закрытый канал
+func (a *JWTAuth) closeSubscribers() {
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+
+	a.subsClosed = true
+	for sub := range a.subs {
+		close(sub.ch)
+	}
+	a.subs = nil
+}
+
+// emit учитывает событие в Status и рассылает его подписчикам без блокировки
+func (a *JWTAuth) emit(event Event) {
+	event.Time = a.clock.Now()
+	a.health.observe(event)
+
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	for sub := range a.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			a.logger.Debug("event subscriber is too slow, event dropped",
+				slog.String("op", "auth.emit"), slog.String("event", event.Type.String()))
+		}
+	}
+}
+
+// OnRefreshed регистрирует fn, вызываемую после каждого обновления токенов со сроком действия нового access токена.
+// Обработчики вызываются в отдельной горутине по порядку событий, поэтому не блокируют обновление,
+// но события, пришедшие пока обработчик занят и буфер заполнен, пропускаются (см. Subscribe).
+// Возвращает функцию отмены регистрации.
+func (a *JWTAuth) OnRefreshed(fn func(expiresAt time.Time)) func() {
+	return a.on(func(event Event) {
+		if event.Type == EventRefreshed {
+			fn(event.ExpiresAt)
+		}
+	})
+}
+
+// OnLoginFailed регистрирует fn, вызываемую при неудачном логине: при старте или после отказа refresh
+func (a *JWTAuth) OnLoginFailed(fn func(err error)) func() {
+	return a.on(func(event Event) {
+		if event.Type == EventLoginFailed {
+			fn(event.Err)
+		}
+	})
+}
+
+// OnRefreshFailed регистрирует fn, вызываемую при неудачном обновлении по refresh токену
+func (a *JWTAuth) OnRefreshFailed(fn func(err error)) func() {
+	return a.on(func(event Event) {
+		if event.Type == EventRefreshFailed {
+			fn(event.Err)
+		}
+	})
+}
+
+// OnStateChange регистрирует fn, вызываемую при каждом изменении состояния клиента
+func (a *JWTAuth) OnStateChange(fn func(from, to State)) func() {
+	return a.on(func(event Event) {
+		if event.Type == EventStateChanged {
+			fn(event.From, event.To)
+		}
+	})
+}
+
+// on подписывает обработчик, вызываемый в отдельной горутине
+func (a *JWTAuth) on(handle func(Event)) func() {
+	events, unsubscribe := a.Subscribe()
+	go func() {
+		for event := range events {
+			handle(event)
+		}
+	}()
+	return unsubscribe
+}
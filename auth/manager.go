@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"github.com/ShlykovPavel/JWTAuth/scheduler"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Manager хранит несколько именованных учётных записей (сервисных аккаунтов, тенантов) с собственными
+// эндпоинтами и учётными данными. Логин выполняется лениво при первом запросе токена,
+// а обновления всех записей планируются в одной общей очереди вместо горутины и таймера на каждую.
+type Manager struct {
+	mu         sync.RWMutex
+	identities map[string]*identity
+	defaults   []Option
+	logger     *slog.Logger
+	queue      *scheduler.Queue
+	stopped    bool
+	// seq делает ключи очереди уникальными, чтобы удалённая и заново добавленная запись с тем же именем не пересекались
+	seq atomic.Uint64
+}
+
+// identity - учётная запись Manager
+type identity struct {
+	auth *JWTAuth
+	// mu сериализует ленивый логин: параллельные запросы токена ждут один логин
+	mu      sync.Mutex
+	started bool
+}
+
+// NewManager создаёт менеджер. opts применяются ко всем учётным записям перед их собственными опциями,
+// например общий WithHTTPClient, WithRetryPolicy или WithLogger.
+func NewManager(opts ...Option) *Manager {
+	// Общие опции применяются к пустому клиенту, чтобы взять из них логгер и часы для очереди
+	probe := &JWTAuth{logger: slog.Default(), clock: clock.System}
+	for _, opt := range opts {
+		opt(probe)
+	}
+	return &Manager{
+		identities: make(map[string]*identity),
+		defaults:   opts,
+		logger:     probe.logger,
+		queue:      scheduler.NewQueue(context.Background(), probe.logger, scheduler.WithClock(probe.clock)),
+	}
+}
+
+// Add добавляет учётную запись name. Логин выполняется при первом вызове GetToken.
+func (m *Manager) Add(name string, opts ...Option) error {
+	opts = append(append(append([]Option{}, m.defaults...), opts...), m.withQueue(name))
+	a := newJWTAuth(opts...)
+	if a.loginURL == "" {
+		return fmt.Errorf("identity %q: login URL is required", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return ErrStopped
+	}
+	if _, ok := m.identities[name]; ok {
+		return fmt.Errorf("%w: %q", ErrIdentityExists, name)
+	}
+	m.identities[name] = &identity{auth: a}
+	m.logger.Info("identity added", slog.String("identity", name))
+	return nil
+}
+
+// Remove останавливает и удаляет учётную запись name
+func (m *Manager) Remove(name string) error {
+	m.mu.Lock()
+	id, ok := m.identities[name]
+	delete(m.identities, name)
+	m.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownIdentity, name)
+	}
+	id.auth.Stop()
+	m.logger.Info("identity removed", slog.String("identity", name))
+	return nil
+}
+
+// Get возвращает клиент учётной записи name, например для NewHTTPClient.
+// Клиент возвращается как есть: если токен ещё не запрашивался, логин не выполнен.
+func (m *Manager) Get(name string) (*JWTAuth, error) {
+	id, err := m.identity(name)
+	if err != nil {
+		return nil, err
+	}
+	return id.auth, nil
+}
+
+// GetToken возвращает access токен учётной записи name, выполняя логин при первом обращении
+func (m *Manager) GetToken(name string) (string, error) {
+	return m.GetTokenContext(context.Background(), name)
+}
+
+// GetTokenContext возвращает access токен учётной записи name.
+// ctx ограничивает ленивый логин, но не время жизни учётной записи.
+// Если логин не удался, следующий вызов попробует снова.
+func (m *Manager) GetTokenContext(ctx context.Context, name string) (string, error) {
+	id, err := m.identity(name)
+	if err != nil {
+		return "", err
+	}
+
+	id.mu.Lock()
+	if !id.started {
+		if err := id.auth.start(ctx); err != nil {
+			id.mu.Unlock()
+			return "", fmt.Errorf("identity %q: %w", name, err)
+		}
+		id.started = true
+	}
+	id.mu.Unlock()
+
+	// Запись могла быть удалена или менеджер остановлен, пока выполнялся логин
+	if id.auth.ctx.Err() != nil {
+		return "", fmt.Errorf("identity %q: %w", name, ErrStopped)
+	}
+	return id.auth.GetTokenContext(ctx)
+}
+
+// Stop останавливает все учётные записи и общую очередь обновлений
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	m.stopped = true
+	identities := m.identities
+	m.identities = make(map[string]*identity)
+	m.mu.Unlock()
+
+	for _, id := range identities {
+		id.auth.Stop()
+	}
+	m.queue.Stop()
+}
+
+func (m *Manager) identity(name string) (*identity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.stopped {
+		return nil, ErrStopped
+	}
+	id, ok := m.identities[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownIdentity, name)
+	}
+	return id, nil
+}
+
+// withQueue подключает учётную запись к общей очереди обновлений.
+// Применяется последней, чтобы логгер получил имя учётной записи.
+func (m *Manager) withQueue(name string) Option {
+	return func(a *JWTAuth) {
+		a.queue = m.queue
+		a.queueKey = fmt.Sprintf("%s#%d", name, m.seq.Add(1))
+		a.logger = a.logger.With(slog.String("identity", name))
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestManager(t *testing.T) {
+	idpA, loginsA, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	idpB, loginsB, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	manager := NewManager(WithLogger(newTestLogger()), WithRetryPolicy(requests.LinearRetry{Count: 0}))
+	defer manager.Stop()
+
+	if err := manager.Add("a", WithEndpoints(idpA.URL+"/login", idpA.URL+"/refresh"), WithCredentials("a", "password")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := manager.Add("b", WithEndpoints(idpB.URL+"/login", idpB.URL+"/refresh"), WithCredentials("b", "password")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := manager.Add("a", WithEndpoints(idpA.URL+"/login", "")); !errors.Is(err, ErrIdentityExists) {
+		t.Errorf("expected ErrIdentityExists, got %v", err)
+	}
+	if err := manager.Add("c"); err == nil {
+		t.Error("expected error without login URL")
+	}
+	if loginsA.Load() != 0 || loginsB.Load() != 0 {
+		t.Fatal("login must be lazy")
+	}
+
+	// Параллельные запросы первого токена выполняют один логин
+	var wg sync.WaitGroup
+	tokens := make([]string, 5)
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := manager.GetToken("a")
+			if err != nil {
+				t.Errorf("GetToken failed: %v", err)
+			}
+			tokens[i] = token
+		}()
+	}
+	wg.Wait()
+	for _, token := range tokens {
+		if token != tokens[0] {
+			t.Error("got different tokens for one identity")
+		}
+	}
+	if got := loginsA.Load(); got != 1 {
+		t.Errorf("got %d logins, want 1", got)
+	}
+	tokenB, err := manager.GetToken("b")
+	if err != nil {
+		t.Fatalf("GetToken failed: %v", err)
+	}
+	if tokenB == "" || loginsB.Load() != 1 {
+		t.Error("identities must use their own endpoints")
+	}
+
+	// Обновления обеих записей запланированы в общей очереди
+	if got := manager.queue.Len(); got != 2 {
+		t.Errorf("got %d scheduled refreshes, want 2", got)
+	}
+
+	if err := manager.Remove("a"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := manager.GetToken("a"); !errors.Is(err, ErrUnknownIdentity) {
+		t.Errorf("expected ErrUnknownIdentity, got %v", err)
+	}
+	if got := manager.queue.Len(); got != 1 {
+		t.Errorf("got %d scheduled refreshes after Remove, want 1", got)
+	}
+
+	manager.Stop()
+	if _, err := manager.GetToken("b"); !errors.Is(err, ErrStopped) {
+		t.Errorf("expected ErrStopped, got %v", err)
+	}
+}
+
+// TestManagerRemoveDuringLogin Запись, удалённая во время ленивого логина, не остаётся в общей очереди обновлений
+func TestManagerRemoveDuringLogin(t *testing.T) {
+	arrived, release := make(chan struct{}), make(chan struct{})
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(arrived)
+		<-release
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken":  signTestToken(t, "login", time.Hour),
+			"refreshToken": "refresh",
+		})
+	}))
+	defer idp.Close()
+
+	manager := NewManager(WithLogger(newTestLogger()), WithRetryPolicy(requests.LinearRetry{Count: 0}))
+	defer manager.Stop()
+	if err := manager.Add("a", WithEndpoints(idp.URL+"/login", idp.URL+"/refresh"), WithCredentials("a", "password")); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := manager.GetToken("a")
+		result <- err
+	}()
+	<-arrived
+	if err := manager.Remove("a"); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	close(release)
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, ErrStopped) {
+			t.Errorf("expected ErrStopped, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GetToken did not return")
+	}
+	if got := manager.queue.Len(); got != 0 {
+		t.Errorf("got %d scheduled refreshes for a removed identity, want 0", got)
+	}
+}
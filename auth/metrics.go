@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"time"
+)
+
+// Metrics получает сведения о запросах к сервису авторизации и о токенах клиента.
+// Реализация для Prometheus - metrics.Collector.
+type Metrics interface {
+	requests.Metrics
+	// ObserveTokens вызывается, когда клиент получил новую пару токенов от сервера (renewed)
+	// или загрузил её из хранилища. expiresAt нулевой, если срок действия access токена неизвестен.
+	ObserveTokens(expiresAt time.Time, renewed bool)
+}
+
+// observeTokens сообщает метрикам о новой паре токенов
+func (a *JWTAuth) observeTokens(tokens *requests.Tokens, renewed bool) {
+	if a.metrics == nil {
+		return
+	}
+	expiresAt, _ := accessTokenExpiry(tokens)
+	a.metrics.ObserveTokens(expiresAt, renewed)
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/ShlykovPavel/JWTAuth/scheduler"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Option настраивает JWTAuth при создании через New
+type Option func(*JWTAuth)
+
+// WithEndpoints задаёт URL для логина и обновления токенов.
+// Если refreshURL пуст, вместо обновления каждый раз выполняется повторный логин.
+func WithEndpoints(loginURL, refreshURL string) Option {
+	return func(a *JWTAuth) {
+		a.loginURL = loginURL
+		a.refreshURL = refreshURL
+	}
+}
+
+// WithCredentials задаёт логин и пароль сервисного аккаунта
+func WithCredentials(username, password string) Option {
+	return func(a *JWTAuth) {
+		a.credentials = &requests.Credentials{Username: username, Password: password}
+	}
+}
+
+// WithHTTPClient задаёт HTTP клиент для запросов к сервису авторизации
+func WithHTTPClient(client *http.Client) Option {
+	return func(a *JWTAuth) {
+		a.httpClient = client
+	}
+}
+
+// WithLogger задаёт логгер. По умолчанию используется slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *JWTAuth) {
+		a.logger = logger
+	}
+}
+
+// WithRetryPolicy задаёт политику повторов запросов логина и обновления.
+// По умолчанию requests.ExponentialBackoff с параметрами по умолчанию: до 4 попыток, не дольше 2 минут.
+func WithRetryPolicy(policy requests.RetryPolicy) Option {
+	return func(a *JWTAuth) {
+		a.retryPolicy = policy
+	}
+}
+
+// WithClientCredentials настраивает получение токенов по OAuth2 client_credentials grant.
+// tokenURL используется как URL логина; refresh токен обычно не выдаётся, поэтому
+// перед истечением access токена grant выполняется заново. Scopes, audience и способ
+// передачи client_secret задаются в grant.
+func WithClientCredentials(tokenURL, clientID, clientSecret string, grant requests.ClientCredentialsCodec) Option {
+	return func(a *JWTAuth) {
+		a.loginURL = tokenURL
+		a.refreshURL = ""
+		a.credentials = &requests.Credentials{Username: clientID, Password: clientSecret}
+		a.codec = grant
+	}
+}
+
+// WithPasswordGrant настраивает получение токенов по OAuth2 password grant
+// и их обновление по refresh_token grant. Оба запроса отправляются на tokenURL.
+// Данные клиента (client_id, client_secret), scopes и способ их передачи задаются в grant.
+func WithPasswordGrant(tokenURL, username, password string, grant requests.PasswordGrantCodec) Option {
+	return func(a *JWTAuth) {
+		a.loginURL = tokenURL
+		a.refreshURL = tokenURL
+		a.credentials = &requests.Credentials{Username: username, Password: password}
+		a.codec = grant
+	}
+}
+
+// WithCodec задаёт формат запросов и ответов эндпоинтов логина и обновления.
+// По умолчанию requests.JSONCodec (поля accessKey/secretKey и accessToken/refreshToken).
+func WithCodec(codec requests.TokenEndpointCodec) Option {
+	return func(a *JWTAuth) {
+		a.codec = codec
+	}
+}
+
+// WithRefreshSkew задаёт, за сколько до истечения access токена выполняется обновление.
+// По умолчанию scheduler.DefaultRefreshSkew.
+func WithRefreshSkew(skew time.Duration) Option {
+	return func(a *JWTAuth) {
+		a.refreshSkew = skew
+	}
+}
+
+// WithRefreshStrategy задаёт стратегию выбора момента обновления, например
+// scheduler.LifetimeFraction(0.8) или scheduler.Jittered(scheduler.FixedSkew(time.Minute), 0.1).
+// Заменяет WithRefreshSkew.
+func WithRefreshStrategy(strategy scheduler.RefreshStrategy) Option {
+	return func(a *JWTAuth) {
+		a.refreshStrategy = strategy
+	}
+}
+
+// WithRefreshInterval ограничивает задержку перед обновлением снизу и сверху,
+// по умолчанию не меньше scheduler.DefaultMinRefreshInterval и без ограничения сверху (maxInterval 0).
+func WithRefreshInterval(minInterval, maxInterval time.Duration) Option {
+	return func(a *JWTAuth) {
+		a.minRefreshInterval = minInterval
+		a.maxRefreshInterval = maxInterval
+	}
+}
+
+// WithExpirySkew задаёт, за сколько до истечения GetToken считает access токен устаревшим
+// и синхронно обновляет его перед возвратом. По умолчанию DefaultExpirySkew.
+func WithExpirySkew(skew time.Duration) Option {
+	return func(a *JWTAuth) {
+		a.expirySkew = skew
+	}
+}
+
+// WithClock задаёт источник текущего времени и таймеров, по умолчанию clock.System.
+// Используется при проверке срока действия токенов, планировании обновлений и ожидании между попытками.
+func WithClock(c clock.Clock) Option {
+	return func(a *JWTAuth) {
+		a.clock = c
+	}
+}
+
+// WithVerifier включает проверку каждого полученного access токена: подписи по набору ключей и claims iss, aud, nbf, exp.
+// Токен, не прошедший проверку, не сохраняется, а логин или refresh завершается ошибкой ErrInvalidToken.
+func WithVerifier(v *JWTParser.Verifier) Option {
+	return func(a *JWTAuth) {
+		a.verifier = v
+	}
+}
+
+// WithTokenStore сохраняет каждую полученную пару токенов в хранилище.
+// При старте ещё действующие токены загружаются из него вместо логина, а истекающие сначала обновляются.
+func WithTokenStore(store TokenStore) Option {
+	return func(a *JWTAuth) {
+		a.store = store
+	}
+}
+
+// WithMetrics включает сбор метрик логина, обновления и срока действия токенов,
+// например metrics.Collector для Prometheus
+func WithMetrics(m Metrics) Option {
+	return func(a *JWTAuth) {
+		a.metrics = m
+	}
+}
+
+// WithTracerProvider включает трассировку OpenTelemetry: спаны Start, планового обновления, renew,
+// операций логина и обновления и каждой попытки HTTP запроса. Сервису авторизации передаётся заголовок traceparent.
+// Атрибуты спанов не содержат учётных данных и токенов.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(a *JWTAuth) {
+		a.tracerProvider = tp
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import "log/slog"
+
+// State - состояние аутентификации JWTAuth
+type State int
+
+const (
+	// StateIdle клиент создан, но Start ещё не вызывался
+	StateIdle State = iota
+	// StateAuthenticated клиент держит действующие токены, обновление запланировано
+	StateAuthenticated
+	// StateRefreshing выполняется обновление токенов
+	StateRefreshing
+	// StateRecovering refresh и повторный логин не удались, клиент повторяет попытки с нарастающей задержкой
+	StateRecovering
+	// StateStopped вызван Stop, токены больше не обновляются
+	StateStopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateAuthenticated:
+		return "authenticated"
+	case StateRefreshing:
+		return "refreshing"
+	case StateRecovering:
+		return "recovering"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// State возвращает текущее состояние аутентификации
+func (a *JWTAuth) State() State {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	return a.state
+}
+
+// setState переключает состояние, логирует переход и сообщает о нём подписчикам.
+// Из StateStopped клиент не выходит, чтобы завершающиеся горутины не "оживили" его.
+func (a *JWTAuth) setState(next State) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+	prev := a.state
+	if prev == next || prev == StateStopped {
+		return
+	}
+	a.state = next
+	// Событие отправляется под блокировкой, чтобы подписчики видели переходы в том же порядке
+	a.emit(Event{Type: EventStateChanged, From: prev, To: next})
+
+	a.logger.Info("auth state changed",
+		slog.String("from", prev.String()),
+		slog.String("to", next.String()))
+}
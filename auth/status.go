@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultStallThreshold - насколько плановое обновление может опоздать, прежде чем HealthHandler сочтёт клиент неготовым
+const DefaultStallThreshold = time.Minute
+
+// Status - снимок состояния клиента для проверок готовности и диагностики
+type Status struct {
+	State State
+	// ExpiresAt - срок действия текущего access токена, нулевой если токена нет или срок неизвестен
+	ExpiresAt time.Time
+	// NextRefresh - момент запланированного обновления, нулевой если обновление не запланировано
+	NextRefresh time.Time
+	// LastRenewal - когда токены последний раз получены от сервиса авторизации
+	LastRenewal time.Time
+	// LastError - последняя ошибка логина или обновления, сохраняется и после успешного обновления
+	LastError   error
+	LastErrorAt time.Time
+	// ConsecutiveFailures - число неудачных обновлений подряд, сбрасывается после успешного
+	ConsecutiveFailures int
+}
+
+// Ready сообщает, готов ли клиент выдавать токены в момент now. Клиент не готов, если он не запущен
+// или остановлен, если access токен истёк или если запланированное обновление опаздывает больше чем на stallThreshold
+// (например, клиент слишком долго находится в состоянии recovering).
+func (s Status) Ready(now time.Time, stallThreshold time.Duration) error {
+	switch {
+	case s.State == StateIdle:
+		return ErrNotAuthenticated
+	case s.State == StateStopped:
+		return ErrStopped
+	case !s.ExpiresAt.IsZero() && !s.ExpiresAt.After(now):
+		return ErrTokenExpired
+	case !s.NextRefresh.IsZero() && now.Sub(s.NextRefresh) > stallThreshold:
+		return fmt.Errorf("%w: refresh is overdue since %s", ErrRefreshStalled, s.NextRefresh.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Status возвращает текущее состояние клиента, срок действия токена, время следующего обновления и последние ошибки
+func (a *JWTAuth) Status() Status {
+	a.health.mu.Lock()
+	status := Status{
+		NextRefresh:         a.health.nextRefresh,
+		LastRenewal:         a.health.lastRenewal,
+		LastError:           a.health.lastError,
+		LastErrorAt:         a.health.lastErrorAt,
+		ConsecutiveFailures: a.health.failures,
+	}
+	a.health.mu.Unlock()
+
+	status.State = a.State()
+	a.mu.RLock()
+	if a.tokens != nil {
+		status.ExpiresAt, _ = accessTokenExpiry(a.tokens)
+	}
+	a.mu.RUnlock()
+	return status
+}
+
+// health накапливает сведения для Status по событиям клиента
+type health struct {
+	mu          sync.Mutex
+	nextRefresh time.Time
+	lastRenewal time.Time
+	lastError   error
+	lastErrorAt time.Time
+	failures    int
+}
+
+func (h *health) observe(event Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch event.Type {
+	case EventLoggedIn, EventRefreshed:
+		h.lastRenewal = event.Time
+		h.failures = 0
+	case EventRefreshFailed:
+		// Обновление ещё может завершиться повторным логином, неудачей считается только отказ логина
+		h.lastError, h.lastErrorAt = event.Err, event.Time
+	case EventLoginFailed:
+		h.lastError, h.lastErrorAt = event.Err, event.Time
+		h.failures++
+	case EventRefreshScheduled:
+		h.nextRefresh = event.RefreshAt
+	case EventStateChanged:
+		if event.To == StateStopped {
+			h.nextRefresh = time.Time{}
+		}
+	}
+}
+
+// HealthOption настраивает HealthHandler
+type HealthOption func(*healthHandler)
+
+// WithStallThreshold задаёт, насколько плановое обновление может опоздать, по умолчанию DefaultStallThreshold
+func WithStallThreshold(threshold time.Duration) HealthOption {
+	return func(h *healthHandler) {
+		h.stallThreshold = threshold
+	}
+}
+
+// HealthHandler возвращает http.Handler, отдающий Status клиента в JSON. Если клиент не готов (см. Status.Ready),
+// ответ имеет код 503 и поле reason, что позволяет использовать обработчик как readiness probe:
+//
+//	mux.Handle("/health/auth", auth.HealthHandler(jwtauth))
+func HealthHandler(a *JWTAuth, opts ...HealthOption) http.Handler {
+	h := &healthHandler{auth: a, stallThreshold: DefaultStallThreshold}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type healthHandler struct {
+	auth           *JWTAuth
+	stallThreshold time.Duration
+}
+
+// statusResponse - JSON представление Status. Нулевые моменты времени и пустая ошибка опускаются
+type statusResponse struct {
+	Ready               bool       `json:"ready"`
+	Reason              string     `json:"reason,omitempty"`
+	State               string     `json:"state"`
+	ExpiresAt           *time.Time `json:"expires_at,omitempty"`
+	NextRefresh         *time.Time `json:"next_refresh,omitempty"`
+	LastRenewal         *time.Time `json:"last_renewal,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+}
+
+func (h *healthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	status := h.auth.Status()
+	resp := statusResponse{
+		Ready:               true,
+		State:               status.State.String(),
+		ExpiresAt:           timeOrNil(status.ExpiresAt),
+		NextRefresh:         timeOrNil(status.NextRefresh),
+		LastRenewal:         timeOrNil(status.LastRenewal),
+		LastErrorAt:         timeOrNil(status.LastErrorAt),
+		ConsecutiveFailures: status.ConsecutiveFailures,
+	}
+	if status.LastError != nil {
+		resp.LastError = status.LastError.Error()
+	}
+	code := http.StatusOK
+	if err := status.Ready(h.auth.clock.Now(), h.stallThreshold); err != nil {
+		resp.Ready = false
+		resp.Reason = err.Error()
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.auth.logger.Debug("failed to write health response", slog.String("op", "auth.HealthHandler"), "error", err)
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/ShlykovPavel/JWTAuth/authtest"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusReady(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		testName string
+		status   Status
+		wantErr  error
+	}{
+		{"PositiveAuthenticated", Status{State: StateAuthenticated, ExpiresAt: now.Add(time.Hour), NextRefresh: now.Add(time.Minute)}, nil},
+		{"PositiveOpaqueToken", Status{State: StateAuthenticated}, nil},
+		{"PositiveRecoveringWithinThreshold", Status{State: StateRecovering, ExpiresAt: now.Add(time.Minute), NextRefresh: now.Add(-30 * time.Second)}, nil},
+		{"NegativeIdle", Status{State: StateIdle}, ErrNotAuthenticated},
+		{"NegativeStopped", Status{State: StateStopped, ExpiresAt: now.Add(time.Hour)}, ErrStopped},
+		{"NegativeExpired", Status{State: StateRecovering, ExpiresAt: now, NextRefresh: now.Add(-time.Second)}, ErrTokenExpired},
+		{"NegativeStalled", Status{State: StateRecovering, ExpiresAt: now.Add(time.Hour), NextRefresh: now.Add(-2 * time.Minute)}, ErrRefreshStalled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if err := tt.status.Ready(now, time.Minute); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	provider := authtest.NewProvider(authtest.WithClock(fake))
+	defer provider.Close()
+
+	jwtauth, err := New(
+		WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+		WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 1}),
+		WithLogger(newTestLogger()),
+		WithClock(fake),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+	handler := HealthHandler(jwtauth, WithStallThreshold(30*time.Second))
+	check := func(wantCode int) statusResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		var resp statusResponse
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("failed to decode response: %v", err)
+		}
+		if rec.Code != wantCode {
+			t.Fatalf("got code %d, want %d: %+v", rec.Code, wantCode, resp)
+		}
+		return resp
+	}
+
+	if resp := check(http.StatusServiceUnavailable); resp.State != "idle" || resp.Ready {
+		t.Errorf("got %+v before Start", resp)
+	}
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	resp := check(http.StatusOK)
+	wantRefresh := fake.Now().Add(authtest.DefaultAccessTokenLifetime - time.Minute)
+	if resp.NextRefresh == nil || !resp.NextRefresh.Equal(wantRefresh) {
+		t.Errorf("got next refresh %v, want %v", resp.NextRefresh, wantRefresh)
+	}
+	if resp.ExpiresAt == nil || resp.LastRenewal == nil || resp.LastError != "" {
+		t.Errorf("got %+v after Start", resp)
+	}
+
+	// Сервис авторизации недоступен: клиент восстанавливается, пока опоздание не превысит порог
+	provider.FailAlways(authtest.Refresh, authtest.ServerError)
+	provider.FailAlways(authtest.Login, authtest.ServerError)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fake.BlockUntil(ctx, 1); err != nil {
+		t.Fatalf("refresh was not scheduled: %v", err)
+	}
+	fake.Advance(wantRefresh.Sub(fake.Now()))
+	waitForState(t, jwtauth, StateRecovering)
+	if resp := check(http.StatusOK); resp.ConsecutiveFailures != 1 || resp.LastError == "" {
+		t.Errorf("got %+v while recovering", resp)
+	}
+	if err := fake.BlockUntil(ctx, 1); err != nil {
+		t.Fatalf("recovery was not scheduled: %v", err)
+	}
+	fake.Advance(jwtauth.recoveryMinBackoff)
+	for jwtauth.Status().ConsecutiveFailures != 2 {
+		if ctx.Err() != nil {
+			t.Fatalf("got %d failures, want 2", jwtauth.Status().ConsecutiveFailures)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(30 * time.Second)
+	if resp := check(http.StatusServiceUnavailable); resp.Reason == "" || resp.State != "recovering" {
+		t.Errorf("got %+v when refresh is stalled", resp)
+	}
+
+	// После восстановления клиент снова готов, счётчик неудач сброшен, последняя ошибка сохранена
+	provider.ClearFaults()
+	if err := jwtauth.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if resp := check(http.StatusOK); resp.ConsecutiveFailures != 0 || resp.LastError == "" || resp.LastErrorAt == nil {
+		t.Errorf("got %+v after recovery", resp)
+	}
+
+	jwtauth.Stop()
+	if resp := check(http.StatusServiceUnavailable); resp.NextRefresh != nil {
+		t.Errorf("got next refresh %v after Stop", resp.NextRefresh)
+	}
+}
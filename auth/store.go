@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+)
+
+// TokenStore сохраняет токены между перезапусками процесса, чтобы не выполнять логин при каждом старте.
+// Реализации: tokenstore.Memory и tokenstore.File.
+type TokenStore interface {
+	// Load возвращает сохранённые по ключу токены или nil, если их нет
+	Load(ctx context.Context, key string) (*requests.Tokens, error)
+	// Save сохраняет токены по ключу, заменяя предыдущие
+	Save(ctx context.Context, key string, tokens requests.Tokens) error
+}
+
+// storeKey - ключ токенов в хранилище: хеш URL логина и имени пользователя.
+// Реплики с одинаковой конфигурацией используют одни токены, учётные записи с разными данными не пересекаются.
+func (a *JWTAuth) storeKey() string {
+	sum := sha256.Sum256([]byte(a.loginURL + "\n" + a.credentials.Username))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadTokens загружает токены из хранилища. Ошибки хранилища не мешают старту: выполняется обычный логин
+func (a *JWTAuth) loadTokens(ctx context.Context) *requests.Tokens {
+	if a.store == nil {
+		return nil
+	}
+	log := a.logger.With(slog.String("op", "auth.loadTokens"))
+	tokens, err := a.store.Load(ctx, a.storeKey())
+	if err != nil {
+		log.Warn("failed to load tokens from store", "error", err)
+		return nil
+	}
+	if tokens == nil {
+		log.Debug("no tokens in store")
+		return nil
+	}
+	// Истёкший access токен не повод для логина: он будет обновлён по refresh токену
+	if err := a.verify(ctx, tokens); err != nil && !errors.Is(err, jwt.ErrTokenExpired) {
+		return nil
+	}
+	log.Info("tokens loaded from store")
+	return tokens
+}
+
+// saveTokens сохраняет новую пару токенов. Ошибка только логируется: токены уже получены и используются
+func (a *JWTAuth) saveTokens(ctx context.Context, tokens *requests.Tokens) {
+	if a.store == nil {
+		return
+	}
+	if err := a.store.Save(ctx, a.storeKey(), *tokens); err != nil {
+		a.logger.Warn("failed to save tokens to store", slog.String("op", "auth.saveTokens"), "error", err)
+	}
+}
+
+// accessTokenFresh сообщает, можно ли использовать access токен без обновления: стратегия обновления
+// (WithRefreshStrategy или refreshSkew) ещё не требует его обновить. Токен без известного срока действия считается устаревшим.
+func (a *JWTAuth) accessTokenFresh(tokens *requests.Tokens) bool {
+	lifetime, ok := accessTokenLifetime(tokens)
+	return ok && a.refreshStrategy.RefreshIn(a.clock.Now(), lifetime) > 0
+}
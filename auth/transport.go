@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// Transport - http.RoundTripper, который добавляет в каждый запрос заголовок
+// Authorization: Bearer <token> с актуальным access токеном из JWTAuth.
+//
+// Если сервер ответил 401, Transport один раз принудительно обновляет токены
+// (refresh, а при неудаче - повторный логин) и повторяет запрос с новым токеном.
+// Запрос с телом, которое нельзя перечитать (не задан GetBody), не повторяется -
+// вызывающему возвращается исходный ответ 401.
+type Transport struct {
+	// Auth источник токенов
+	Auth *JWTAuth
+	// Base транспорт для выполнения запросов. Если nil, используется http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// NewHTTPClient создаёт http.Client, который авторизует все запросы токенами из a.
+// Таймаут и прочие настройки копируются из base, его транспорт используется как базовый.
+// Если base равен nil, используется http.DefaultClient.
+func NewHTTPClient(a *JWTAuth, base *http.Client) *http.Client {
+	if base == nil {
+		base = http.DefaultClient
+	}
+	client := *base
+	client.Transport = &Transport{Auth: a, Base: base.Transport}
+	return &client
+}
+
+// RoundTrip реализует интерфейс http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	const op = "auth.Transport.RoundTrip"
+	log := t.Auth.logger.With(
+		slog.String("op", op),
+		slog.String("url", req.URL.Redacted()))
+
+	token, err := t.Auth.GetTokenContext(req.Context())
+	if err != nil {
+		closeRequestBody(req)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	resp, err := t.base().RoundTrip(withBearer(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if !isReplayable(req) {
+		log.Warn("got 401, request body can not be replayed")
+		return resp, nil
+	}
+
+	log.Debug("got 401, renewing tokens")
+	newToken, err := t.Auth.renewIfCurrent(req.Context(), token)
+	if err != nil {
+		log.Error("failed to renew tokens after 401", "error", err)
+		return resp, nil
+	}
+
+	retry := withBearer(req, newToken)
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			log.Error("failed to rewind request body", "error", err)
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	// Исходный ответ больше не нужен, вычитываем тело, чтобы соединение можно было переиспользовать
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return t.base().RoundTrip(retry)
+}
+
+func (t *Transport) base() http.RoundTripper {
+	if t.Base != nil {
+		return t.Base
+	}
+	return http.DefaultTransport
+}
+
+// withBearer возвращает копию запроса с заголовком Authorization.
+// RoundTripper не должен изменять исходный запрос.
+func withBearer(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
+
+// isReplayable сообщает, можно ли отправить запрос повторно
+func isReplayable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func closeRequestBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
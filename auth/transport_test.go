@@ -0,0 +1,211 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signTestToken выпускает подписанный HS256 токен с заданным временем жизни
+func signTestToken(t *testing.T, subject string, ttl time.Duration) string {
+	t.Helper()
+	return signTestTokenAt(t, subject, time.Now(), ttl)
+}
+
+// signTestTokenAt подписывает токен, выданный в issuedAt, например по поддельным часам
+func signTestTokenAt(t *testing.T, subject string, issuedAt time.Time, ttl time.Duration) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": subject,
+		"iat": issuedAt.Unix(),
+		"exp": issuedAt.Add(ttl).Unix(),
+	}).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+// newTestIdentityServer поднимает тестовый сервер с эндпоинтами /login и /refresh.
+// Каждый успешный ответ содержит новый access токен, счётчики вызовов возвращаются для проверок.
+// Логины после первого отвечают 500, пока не будут исчерпаны loginFailures.
+func newTestIdentityServer(t *testing.T, refreshStatus int, loginFailures int32) (*httptest.Server, *atomic.Int32, *atomic.Int32) {
+	t.Helper()
+	var logins, refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n int32
+		switch r.URL.Path {
+		case "/login":
+			n = logins.Add(1)
+			if n > 1 && n <= loginFailures+1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		case "/refresh":
+			n = refreshes.Add(1)
+			if refreshStatus != http.StatusOK {
+				w.WriteHeader(refreshStatus)
+				return
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"accessToken":  signTestToken(t, fmt.Sprintf("%s-%d", strings.TrimPrefix(r.URL.Path, "/"), n), time.Hour),
+			"refreshToken": "refresh",
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &logins, &refreshes
+}
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestTransport(t *testing.T) {
+	tests := []struct {
+		testName       string
+		refreshStatus  int
+		body           func() io.Reader
+		wantStatus     int
+		wantAPICalls   int32
+		wantLogins     int32
+		wantRefreshes  int32
+		wantReplayBody string
+	}{
+		{"PositiveReplayAfterRefresh", http.StatusOK, nil, http.StatusOK, 2, 1, 1, ""},
+		{"PositiveReplayWithBody", http.StatusOK, func() io.Reader { return strings.NewReader("payload") }, http.StatusOK, 2, 1, 1, "payload"},
+		{"PositiveReloginWhenRefreshRejected", http.StatusUnauthorized, nil, http.StatusOK, 2, 2, 1, ""},
+		{"NegativeNonReplayableBody", http.StatusOK, func() io.Reader { return io.MultiReader(strings.NewReader("payload")) }, http.StatusUnauthorized, 1, 1, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			idp, logins, refreshes := newTestIdentityServer(t, tt.refreshStatus, 0)
+			jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+			if err := jwtauth.Start(); err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			defer jwtauth.Stop()
+			firstToken, _ := jwtauth.GetToken()
+
+			// API отклоняет первый выданный токен и принимает любой другой
+			var apiCalls atomic.Int32
+			var lastBody string
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				apiCalls.Add(1)
+				body, _ := io.ReadAll(r.Body)
+				lastBody = string(body)
+				if r.Header.Get("Authorization") == "Bearer "+firstToken {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer api.Close()
+
+			var body io.Reader
+			if tt.body != nil {
+				body = tt.body()
+			}
+			req, err := http.NewRequest(http.MethodPost, api.URL, body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := NewHTTPClient(jwtauth, nil).Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("got %d status code, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := apiCalls.Load(); got != tt.wantAPICalls {
+				t.Errorf("got %d api calls, want %d", got, tt.wantAPICalls)
+			}
+			if got := logins.Load(); got != tt.wantLogins {
+				t.Errorf("got %d logins, want %d", got, tt.wantLogins)
+			}
+			if got := refreshes.Load(); got != tt.wantRefreshes {
+				t.Errorf("got %d refreshes, want %d", got, tt.wantRefreshes)
+			}
+			if tt.wantReplayBody != "" && lastBody != tt.wantReplayBody {
+				t.Errorf("replayed body %q, want %q", lastBody, tt.wantReplayBody)
+			}
+		})
+	}
+}
+
+func TestTransportAddsBearer(t *testing.T) {
+	idp, _, _ := newTestIdentityServer(t, http.StatusOK, 0)
+	jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer jwtauth.Stop()
+	token, _ := jwtauth.GetToken()
+
+	var gotHeader string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header.Get("Authorization")
+	}))
+	defer api.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, api.URL, bytes.NewReader(nil))
+	resp, err := NewHTTPClient(jwtauth, nil).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	if gotHeader != "Bearer "+token {
+		t.Errorf("got Authorization %q, want %q", gotHeader, "Bearer "+token)
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Error("original request must not be modified")
+	}
+}
+
+func TestTransportAfterFailedStart(t *testing.T) {
+	idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer idp.Close()
+	jwtauth := NewJwtAuth(idp.URL+"/login", idp.URL+"/refresh", "user", "password", 0, newTestLogger())
+	defer jwtauth.Stop()
+	if err := jwtauth.Start(); err == nil {
+		t.Fatal("expected Start error")
+	}
+
+	var apiCalls atomic.Int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiCalls.Add(1)
+	}))
+	defer api.Close()
+
+	// Запрос не ждёт первого логина, который уже не удался, а сразу завершается ошибкой
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, api.URL, nil)
+	_, err := NewHTTPClient(jwtauth, nil).Do(req)
+	if !errors.Is(err, ErrNotAuthenticated) || !errors.Is(err, requests.ErrInvalidCredentials) {
+		t.Fatalf("got %v, want ErrNotAuthenticated caused by invalid credentials", err)
+	}
+	if apiCalls.Load() != 0 {
+		t.Error("request without token must not reach the API")
+	}
+}
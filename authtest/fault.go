@@ -0,0 +1,109 @@
+package authtest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Fault - сбой, которым Provider отвечает вместо обычного ответа
+type Fault struct {
+	// Status код ответа. 0 - обычный ответ, например для медленного ответа через Delay
+	Status int
+	// RetryAfter значение заголовка Retry-After в секундах, отправляется вместе с Status
+	RetryAfter int
+	// Delay задержка перед ответом
+	Delay time.Duration
+	// Hang - не отвечать, пока клиент не прервёт запрос (например, по таймауту) или Provider не будет закрыт
+	Hang bool
+}
+
+// Status возвращает сбой с кодом ответа, например Status(http.StatusServiceUnavailable)
+func Status(code int) Fault {
+	return Fault{Status: code}
+}
+
+// Slow возвращает обычный ответ с задержкой d
+func Slow(d time.Duration) Fault {
+	return Fault{Delay: d}
+}
+
+var (
+	// Timeout - запрос зависает до таймаута клиента
+	Timeout = Fault{Hang: true}
+	// Unauthorized - ответ 401; на refresh клиент выполняет повторный логин
+	Unauthorized = Status(http.StatusUnauthorized)
+	// ServerError - ответ 500, который клиент повторяет по RetryPolicy
+	ServerError = Status(http.StatusInternalServerError)
+)
+
+// FailNext задаёт сбои для следующих запросов к endpoint: по одному сбою на запрос, по порядку
+func (p *Provider) FailNext(endpoint Endpoint, faults ...Fault) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.faults[endpoint] = append(p.faults[endpoint], faults...)
+}
+
+// FailAlways задаёт сбой для всех запросов к endpoint до вызова ClearFaults.
+// Сбои FailNext применяются раньше.
+func (p *Provider) FailAlways(endpoint Endpoint, fault Fault) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.always[endpoint] = fault
+}
+
+// ClearFaults отменяет все заданные сбои
+func (p *Provider) ClearFaults() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.faults)
+	clear(p.always)
+}
+
+// nextFault возвращает сбой для очередного запроса к endpoint
+func (p *Provider) nextFault(endpoint Endpoint) (Fault, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if queue := p.faults[endpoint]; len(queue) > 0 {
+		p.faults[endpoint] = queue[1:]
+		return queue[0], true
+	}
+	fault, ok := p.always[endpoint]
+	return fault, ok
+}
+
+// applyFault применяет сбой к запросу. Возвращает true, если запрос нужно обработать как обычно
+func (p *Provider) applyFault(w http.ResponseWriter, r *http.Request, call *Call) bool {
+	fault, ok := p.nextFault(call.Endpoint)
+	if !ok {
+		return true
+	}
+	// Прерванный запрос остаётся записанным со статусом 0
+	if fault.Delay > 0 && !p.wait(r, time.After(fault.Delay)) {
+		return false
+	}
+	if fault.Hang {
+		p.wait(r, nil)
+		return false
+	}
+	if fault.Status == 0 {
+		return true
+	}
+	if fault.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(fault.RetryAfter))
+	}
+	p.respond(w, call, fault.Status, nil)
+	return false
+}
+
+// wait ждёт done и возвращает true, либо возвращает false, если клиент прервал запрос или Provider закрыт
+func (p *Provider) wait(r *http.Request, done <-chan time.Time) bool {
+	select {
+	case <-done:
+		return true
+	case <-r.Context().Done():
+		return false
+	case <-p.closed:
+		return false
+	}
+}
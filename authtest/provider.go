@@ -0,0 +1,395 @@
+// Package authtest содержит поддельный сервер авторизации для тестов сервисов, использующих JWTAuth.
+// Сервер выдаёт настоящие подписанные JWT с заданным временем жизни, ротирует refresh токены,
+// умеет отвечать ошибками и медленно и записывает все запросы для проверок.
+package authtest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/golang-jwt/jwt/v5"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+)
+
+// Пути эндпоинтов Provider
+const (
+	LoginPath   = "/login"
+	RefreshPath = "/refresh-tokens"
+	JWKSPath    = "/.well-known/jwks.json"
+)
+
+// Значения Provider по умолчанию
+const (
+	DefaultAccessTokenLifetime  = time.Hour
+	DefaultRefreshTokenLifetime = 24 * time.Hour
+	DefaultIssuer               = "authtest"
+	// KeyID идентификатор ключа подписи (kid)
+	KeyID = "authtest"
+)
+
+// Endpoint - эндпоинт Provider, для которого задаются сбои и записываются вызовы
+type Endpoint string
+
+const (
+	Login   Endpoint = "login"
+	Refresh Endpoint = "refresh"
+)
+
+// Call - запрос к Provider
+type Call struct {
+	Endpoint Endpoint
+	// Time время запроса по часам Provider
+	Time time.Time
+	// Username логин из запроса логина
+	Username string
+	// RefreshToken refresh токен из запроса обновления
+	RefreshToken string
+	// Header заголовки запроса, например traceparent
+	Header http.Header
+	// Status код ответа; 0, пока ответ не отправлен или если запрос был прерван (Timeout)
+	Status int
+}
+
+// Provider - поддельный сервер авторизации в формате requests.JSONCodec:
+// POST LoginPath с accessKey/secretKey и POST RefreshPath с accessToken/refreshToken.
+// Access токены подписаны ES256, открытый ключ доступен по JWKSPath и через KeySet.
+type Provider struct {
+	server *httptest.Server
+	key    *ecdsa.PrivateKey
+
+	accessLifetime  time.Duration
+	refreshLifetime time.Duration
+	issuer          string
+	audience        string
+	claims          jwt.MapClaims
+	credentials     *requests.Credentials
+	clock           clock.Clock
+
+	mu sync.Mutex
+	// refreshTokens - действующие refresh токены и логин, для которого они выданы.
+	// Использованный токен удаляется: повторное обновление по нему получает 401.
+	refreshTokens map[string]string
+	calls         []*Call
+	faults        map[Endpoint][]Fault
+	always        map[Endpoint]Fault
+	seq           int
+	// closed закрывается в Close и освобождает зависшие запросы
+	closed chan struct{}
+}
+
+// Option настраивает Provider
+type Option func(*Provider)
+
+// WithTokenLifetime задаёт время жизни access и refresh токенов.
+// По умолчанию DefaultAccessTokenLifetime и DefaultRefreshTokenLifetime.
+func WithTokenLifetime(access, refresh time.Duration) Option {
+	return func(p *Provider) {
+		p.accessLifetime = access
+		p.refreshLifetime = refresh
+	}
+}
+
+// WithCredentials разрешает логин только с этими логином и паролем, остальные получают 401.
+// По умолчанию принимаются любые.
+func WithCredentials(username, password string) Option {
+	return func(p *Provider) {
+		p.credentials = &requests.Credentials{Username: username, Password: password}
+	}
+}
+
+// WithIssuer задаёт claim iss, по умолчанию DefaultIssuer
+func WithIssuer(issuer string) Option {
+	return func(p *Provider) {
+		p.issuer = issuer
+	}
+}
+
+// WithAudience задаёт claim aud, по умолчанию он не выставляется
+func WithAudience(audience string) Option {
+	return func(p *Provider) {
+		p.audience = audience
+	}
+}
+
+// WithClaims добавляет claims в каждый access токен, например scope, roles или tenant
+func WithClaims(claims jwt.MapClaims) Option {
+	return func(p *Provider) {
+		p.claims = claims
+	}
+}
+
+// WithClock задаёт часы для iat, exp и проверки срока refresh токенов, по умолчанию clock.System.
+// Используйте те же часы, что и у JWTAuth (auth.WithClock).
+func WithClock(c clock.Clock) Option {
+	return func(p *Provider) {
+		p.clock = c
+	}
+}
+
+// NewProvider запускает Provider. Остановите его через Close, например в t.Cleanup.
+func NewProvider(opts ...Option) *Provider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(fmt.Sprintf("authtest: generate signing key: %v", err))
+	}
+	p := &Provider{
+		key:             key,
+		accessLifetime:  DefaultAccessTokenLifetime,
+		refreshLifetime: DefaultRefreshTokenLifetime,
+		issuer:          DefaultIssuer,
+		clock:           clock.System,
+		refreshTokens:   make(map[string]string),
+		faults:          make(map[Endpoint][]Fault),
+		always:          make(map[Endpoint]Fault),
+		closed:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+LoginPath, p.handleLogin)
+	mux.HandleFunc("POST "+RefreshPath, p.handleRefresh)
+	mux.HandleFunc("GET "+JWKSPath, p.handleJWKS)
+	p.server = httptest.NewServer(mux)
+	return p
+}
+
+// Close освобождает зависшие запросы и останавливает сервер
+func (p *Provider) Close() {
+	p.mu.Lock()
+	select {
+	case <-p.closed:
+	default:
+		close(p.closed)
+	}
+	p.mu.Unlock()
+	p.server.Close()
+}
+
+// URL возвращает базовый URL сервера
+func (p *Provider) URL() string {
+	return p.server.URL
+}
+
+// LoginURL и RefreshURL возвращают URL эндпоинтов для auth.WithEndpoints
+func (p *Provider) LoginURL() string {
+	return p.server.URL + LoginPath
+}
+
+func (p *Provider) RefreshURL() string {
+	return p.server.URL + RefreshPath
+}
+
+// JWKSURL возвращает URL набора ключей для JWTParser.NewRemoteKeySet
+func (p *Provider) JWKSURL() string {
+	return p.server.URL + JWKSPath
+}
+
+// KeySet возвращает ключ проверки подписи выданных токенов для JWTParser.NewVerifier
+func (p *Provider) KeySet() *JWTParser.StaticKeySet {
+	return JWTParser.NewStaticKeySet(JWTParser.Key{ID: KeyID, Algorithm: "ES256", Key: &p.key.PublicKey})
+}
+
+// Calls возвращает копию записанных запросов
+func (p *Provider) Calls() []Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	calls := make([]Call, len(p.calls))
+	for i, call := range p.calls {
+		calls[i] = *call
+	}
+	return calls
+}
+
+// CallCount возвращает число запросов к endpoint
+func (p *Provider) CallCount(endpoint Endpoint) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, call := range p.calls {
+		if call.Endpoint == endpoint {
+			n++
+		}
+	}
+	return n
+}
+
+// ResetCalls очищает записанные запросы
+func (p *Provider) ResetCalls() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = nil
+}
+
+// IssueTokens выдаёт пару токенов для username без запроса, например чтобы положить их в TokenStore
+func (p *Provider) IssueTokens(username string) requests.Tokens {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.issueUnlocked(username)
+}
+
+// RevokeRefreshTokens делает недействительными все выданные refresh токены:
+// следующее обновление получит 401, и клиент выполнит повторный логин
+func (p *Provider) RevokeRefreshTokens() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clear(p.refreshTokens)
+}
+
+func (p *Provider) handleLogin(w http.ResponseWriter, r *http.Request) {
+	var credentials requests.Credentials
+	err := json.NewDecoder(r.Body).Decode(&credentials)
+	call := p.record(Call{Endpoint: Login, Username: credentials.Username, Header: r.Header.Clone()})
+	if err != nil {
+		p.respond(w, call, http.StatusBadRequest, nil)
+		return
+	}
+	if !p.applyFault(w, r, call) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.credentials != nil && credentials != *p.credentials {
+		p.respondUnlocked(w, call, http.StatusUnauthorized, nil)
+		return
+	}
+	tokens := p.issueUnlocked(credentials.Username)
+	p.respondUnlocked(w, call, http.StatusOK, &tokens)
+}
+
+func (p *Provider) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var body requests.Tokens
+	err := json.NewDecoder(r.Body).Decode(&body)
+	call := p.record(Call{Endpoint: Refresh, RefreshToken: body.RefreshToken, Header: r.Header.Clone()})
+	if err != nil {
+		p.respond(w, call, http.StatusBadRequest, nil)
+		return
+	}
+	if !p.applyFault(w, r, call) {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	username, ok := p.refreshTokens[body.RefreshToken]
+	if !ok || p.expiredUnlocked(body.RefreshToken) {
+		p.respondUnlocked(w, call, http.StatusUnauthorized, nil)
+		return
+	}
+	// Ротация: старый refresh токен больше не действует
+	delete(p.refreshTokens, body.RefreshToken)
+	call.Username = username
+	tokens := p.issueUnlocked(username)
+	p.respondUnlocked(w, call, http.StatusOK, &tokens)
+}
+
+func (p *Provider) handleJWKS(w http.ResponseWriter, _ *http.Request) {
+	point, err := p.key.PublicKey.ECDH()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Несжатая точка: 0x04, затем координаты X и Y
+	xy := point.Bytes()[1:]
+	size := len(xy) / 2
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+		"kty": "EC",
+		"crv": "P-256",
+		"kid": KeyID,
+		"alg": "ES256",
+		"use": "sig",
+		"x":   base64.RawURLEncoding.EncodeToString(xy[:size]),
+		"y":   base64.RawURLEncoding.EncodeToString(xy[size:]),
+	}}})
+}
+
+func (p *Provider) issueUnlocked(username string) requests.Tokens {
+	now := p.clock.Now()
+	p.seq++
+
+	claims := jwt.MapClaims{}
+	for name, value := range p.claims {
+		claims[name] = value
+	}
+	claims["iss"] = p.issuer
+	claims["sub"] = username
+	claims["iat"] = now.Unix()
+	claims["exp"] = now.Add(p.accessLifetime).Unix()
+	// jti делает токены уникальными, даже если выданы в одну секунду
+	claims["jti"] = fmt.Sprintf("access-%d", p.seq)
+	if p.audience != "" {
+		claims["aud"] = p.audience
+	}
+	access := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	access.Header["kid"] = KeyID
+
+	refresh := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.issuer,
+		"sub": username,
+		"iat": now.Unix(),
+		"exp": now.Add(p.refreshLifetime).Unix(),
+		"jti": fmt.Sprintf("refresh-%d", p.seq),
+	})
+	refresh.Header["kid"] = KeyID
+
+	tokens := requests.Tokens{AccessToken: p.sign(access), RefreshToken: p.sign(refresh)}
+	p.refreshTokens[tokens.RefreshToken] = username
+	return tokens
+}
+
+func (p *Provider) sign(token *jwt.Token) string {
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		panic(fmt.Sprintf("authtest: sign token: %v", err))
+	}
+	return signed
+}
+
+// expiredUnlocked сообщает, что refresh токен истёк по часам Provider
+func (p *Provider) expiredUnlocked(refreshToken string) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(refreshToken, claims); err != nil {
+		return true
+	}
+	exp, err := claims.GetExpirationTime()
+	return err != nil || exp == nil || !exp.After(p.clock.Now())
+}
+
+// record записывает вызов при получении запроса, чтобы он был виден, даже если ответ не будет отправлен
+func (p *Provider) record(call Call) *Call {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	call.Time = p.clock.Now()
+	p.calls = append(p.calls, &call)
+	return &call
+}
+
+func (p *Provider) respond(w http.ResponseWriter, call *Call, status int, tokens *requests.Tokens) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.respondUnlocked(w, call, status, tokens)
+}
+
+// respondUnlocked отвечает status и токенами, если они есть, и сохраняет status в записи вызова
+func (p *Provider) respondUnlocked(w http.ResponseWriter, call *Call, status int, tokens *requests.Tokens) {
+	call.Status = status
+	if tokens == nil {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(tokens)
+}
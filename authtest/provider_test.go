@@ -0,0 +1,165 @@
+package authtest_test
+
+import (
+	"context"
+	"errors"
+	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/ShlykovPavel/JWTAuth/auth"
+	"github.com/ShlykovPavel/JWTAuth/authtest"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func newTestLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestProviderWithJWTAuth(t *testing.T) {
+	provider := authtest.NewProvider(
+		authtest.WithCredentials("user", "password"),
+		authtest.WithClaims(map[string]any{"scope": "read write"}),
+	)
+	defer provider.Close()
+
+	verifier := JWTParser.NewVerifier(JWTParser.NewRemoteKeySet(provider.JWKSURL(), nil),
+		JWTParser.WithIssuer(authtest.DefaultIssuer))
+	jwtauth, err := auth.New(
+		auth.WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+		auth.WithCredentials("user", "password"),
+		auth.WithVerifier(verifier),
+		auth.WithLogger(newTestLogger()),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	first, _ := jwtauth.GetToken()
+	if err := jwtauth.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	second, _ := jwtauth.GetToken()
+	if first == second {
+		t.Error("token was not renewed")
+	}
+
+	claims, err := jwtauth.CurrentClaims()
+	if err != nil {
+		t.Fatalf("CurrentClaims failed: %v", err)
+	}
+	if claims.Subject != "user" || !claims.HasScope("write") {
+		t.Errorf("got subject %q and scopes %v", claims.Subject, claims.Scopes)
+	}
+
+	calls := provider.Calls()
+	if len(calls) != 2 || calls[0].Endpoint != authtest.Login || calls[1].Endpoint != authtest.Refresh {
+		t.Fatalf("got calls %+v, want login and refresh", calls)
+	}
+	if calls[1].Username != "user" || calls[1].Status != http.StatusOK {
+		t.Errorf("got refresh call %+v", calls[1])
+	}
+
+	// Refresh токен ротирован: повторное обновление по старому отклоняется
+	client := requests.NewClient(nil, requests.WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 1}))
+	_, err = client.Refresh(context.Background(), provider.RefreshURL(), requests.Tokens{RefreshToken: calls[1].RefreshToken}, newTestLogger())
+	if !errors.Is(err, requests.ErrRefreshTokenExpired) {
+		t.Errorf("got %v, want ErrRefreshTokenExpired for reused refresh token", err)
+	}
+}
+
+func TestProviderFaults(t *testing.T) {
+	tests := []struct {
+		testName    string
+		endpoint    authtest.Endpoint
+		faults      []authtest.Fault
+		wantErr     error
+		wantLogins  int
+		wantStatus  []int
+		refreshOnly bool
+	}{
+		{"PositiveRetryAfterServerError", authtest.Login, []authtest.Fault{authtest.ServerError}, nil, 2, []int{500, 200}, false},
+		{"PositiveSlowResponse", authtest.Login, []authtest.Fault{authtest.Slow(20 * time.Millisecond)}, nil, 1, []int{200}, false},
+		{"NegativeTimeout", authtest.Login, []authtest.Fault{authtest.Timeout, authtest.Timeout}, requests.ErrTimeout, 2, []int{0, 0}, false},
+		{"NegativeUnavailable", authtest.Login, []authtest.Fault{{Status: 503, RetryAfter: 1}, authtest.Status(503)}, requests.ErrServerUnavailable, 2, []int{503, 503}, false},
+		{"PositiveReloginAfterRejectedRefresh", authtest.Refresh, []authtest.Fault{authtest.Unauthorized}, nil, 2, []int{200, 401, 200}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			provider := authtest.NewProvider()
+			defer provider.Close()
+
+			jwtauth, err := auth.New(
+				auth.WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+				auth.WithHTTPClient(&http.Client{Timeout: 100 * time.Millisecond}),
+				auth.WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 2, InitialInterval: time.Millisecond, MaxInterval: time.Millisecond}),
+				auth.WithLogger(newTestLogger()),
+			)
+			if err != nil {
+				t.Fatalf("New failed: %v", err)
+			}
+			defer jwtauth.Stop()
+
+			provider.FailNext(tt.endpoint, tt.faults...)
+			if tt.refreshOnly {
+				if err := jwtauth.Start(); err != nil {
+					t.Fatalf("Start failed: %v", err)
+				}
+				err = jwtauth.Refresh()
+			} else {
+				err = jwtauth.Start()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+
+			if got := provider.CallCount(authtest.Login); got != tt.wantLogins {
+				t.Errorf("got %d logins, want %d", got, tt.wantLogins)
+			}
+			var statuses []int
+			for _, call := range provider.Calls() {
+				statuses = append(statuses, call.Status)
+			}
+			if len(statuses) != len(tt.wantStatus) {
+				t.Fatalf("got statuses %v, want %v", statuses, tt.wantStatus)
+			}
+			for i := range statuses {
+				if statuses[i] != tt.wantStatus[i] {
+					t.Errorf("got statuses %v, want %v", statuses, tt.wantStatus)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestProviderTokenLifetime(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	provider := authtest.NewProvider(
+		authtest.WithTokenLifetime(time.Minute, time.Hour),
+		authtest.WithClock(fake),
+	)
+	defer provider.Close()
+
+	tokens := provider.IssueTokens("user")
+	claims, err := JWTParser.NewVerifier(provider.KeySet(), JWTParser.WithClock(fake)).Verify(context.Background(), tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if exp, _ := claims.GetExpirationTime(); !exp.Equal(fake.Now().Add(time.Minute)) {
+		t.Errorf("got exp %v, want %v", exp, fake.Now().Add(time.Minute))
+	}
+
+	// Истёкший refresh токен отклоняется, даже если он ещё не использовался
+	fake.Advance(2 * time.Hour)
+	client := requests.NewClient(nil, requests.WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 1}))
+	if _, err := client.Refresh(context.Background(), provider.RefreshURL(), tokens, newTestLogger()); !errors.Is(err, requests.ErrRefreshTokenExpired) {
+		t.Errorf("got %v, want ErrRefreshTokenExpired", err)
+	}
+}
@@ -0,0 +1,44 @@
+package clock
+
+import "time"
+
+// Clock источник текущего времени и таймеров.
+// Позволяет подменять время в тестах и при расчёте срока действия токенов:
+// с поддельными часами из пакета clock/testing обновление, истечение и задержки между попытками
+// проверяются без реального ожидания.
+type Clock interface {
+	Now() time.Time
+	// NewTimer создаёт таймер, срабатывающий через d, аналог time.NewTimer
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer - таймер, созданный Clock. Методы повторяют time.Timer.
+type Timer interface {
+	// C возвращает канал, в который отправляется время срабатывания
+	C() <-chan time.Time
+	// Stop останавливает таймер и возвращает false, если он уже сработал или был остановлен
+	Stop() bool
+	// Reset перезапускает таймер на d и возвращает true, если он был активен
+	Reset(d time.Duration) bool
+}
+
+// System часы, возвращающие системное время
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+type systemTimer struct {
+	*time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,168 @@
+// Package testing содержит поддельные часы для детерминированных тестов.
+// Время в них идёт только при вызове Advance или Set, поэтому обновление токенов,
+// их истечение и задержки между попытками проверяются мгновенно.
+// Импортируйте пакет под другим именем, чтобы не пересекаться со стандартным testing:
+//
+//	import clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+package testing
+
+import (
+	"context"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"slices"
+	"sync"
+	"time"
+)
+
+// FakeClock - поддельные часы. Таймеры срабатывают, когда Advance или Set доводят время до их срока.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+	// changed закрывается и заменяется при каждом изменении набора активных таймеров
+	changed chan struct{}
+}
+
+// NewFakeClock создаёт часы, показывающие now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	t := &fakeTimer{clock: c, c: make(chan time.Time, 1)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.startUnlocked(t, d)
+	return t
+}
+
+// Advance переводит часы вперёд на d и запускает таймеры, срок которых наступил
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setUnlocked(c.now.Add(d))
+}
+
+// Set устанавливает время now и запускает таймеры, срок которых наступил.
+// Перевод часов назад таймеры не запускает.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.setUnlocked(now)
+}
+
+// Waiters возвращает число активных таймеров
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// BlockUntil ждёт, пока активных таймеров станет не меньше n, или отмены ctx.
+// Нужен перед Advance, если таймер создаёт другая горутина, например планировщик обновлений.
+func (c *FakeClock) BlockUntil(ctx context.Context, n int) error {
+	for {
+		c.mu.Lock()
+		waiters, changed := len(c.timers), c.changed
+		c.mu.Unlock()
+		if waiters >= n {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (c *FakeClock) setUnlocked(now time.Time) {
+	c.now = now
+	slices.SortStableFunc(c.timers, func(a, b *fakeTimer) int { return a.deadline.Compare(b.deadline) })
+	fired := 0
+	for _, t := range c.timers {
+		if t.deadline.After(now) {
+			break
+		}
+		t.fire(now)
+		fired++
+	}
+	if fired > 0 {
+		c.timers = slices.Delete(c.timers, 0, fired)
+		c.notifyUnlocked()
+	}
+}
+
+// startUnlocked запускает таймер t на d; таймер с d <= 0 срабатывает сразу
+func (c *FakeClock) startUnlocked(t *fakeTimer, d time.Duration) {
+	t.deadline = c.now.Add(d)
+	if d <= 0 {
+		t.fire(c.now)
+		return
+	}
+	c.timers = append(c.timers, t)
+	c.notifyUnlocked()
+}
+
+// stopUnlocked снимает таймер t и сообщает, был ли он активен
+func (c *FakeClock) stopUnlocked(t *fakeTimer) bool {
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+	c.timers = slices.Delete(c.timers, i, i+1)
+	c.notifyUnlocked()
+	return true
+}
+
+func (c *FakeClock) notifyUnlocked() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	c        chan time.Time
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop и Reset, как и time.Timer начиная с Go 1.23, отбрасывают не прочитанное из канала срабатывание
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.drain()
+	return t.clock.stopUnlocked(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	t.drain()
+	active := t.clock.stopUnlocked(t)
+	t.clock.startUnlocked(t, d)
+	return active
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	select {
+	case t.c <- now:
+	default:
+	}
+}
+
+func (t *fakeTimer) drain() {
+	select {
+	case <-t.c:
+	default:
+	}
+}
@@ -0,0 +1,75 @@
+package testing_test
+
+import (
+	"context"
+	"errors"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	fake := clocktesting.NewFakeClock(start)
+
+	timer := fake.NewTimer(time.Minute)
+	stopped := fake.NewTimer(time.Minute)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Error("Stop must report whether the timer was active")
+	}
+
+	fake.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+	fake.Advance(time.Second)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(start.Add(time.Minute)) {
+			t.Errorf("timer fired at %v, want %v", at, start.Add(time.Minute))
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	select {
+	case <-stopped.C():
+		t.Error("stopped timer fired")
+	default:
+	}
+
+	// Reset после срабатывания запускает таймер заново
+	if timer.Reset(time.Second) {
+		t.Error("Reset of a fired timer must return false")
+	}
+	if got := fake.Waiters(); got != 1 {
+		t.Errorf("got %d waiters, want 1", got)
+	}
+	fake.Set(start.Add(time.Hour))
+	select {
+	case <-timer.C():
+	default:
+		t.Error("timer did not fire after Set")
+	}
+	if got := fake.Waiters(); got != 0 {
+		t.Errorf("got %d waiters, want 0", got)
+	}
+}
+
+func TestFakeClockBlockUntil(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Now())
+	go fake.NewTimer(time.Hour)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := fake.BlockUntil(ctx, 1); err != nil {
+		t.Fatalf("BlockUntil failed: %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := fake.BlockUntil(ctx, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want deadline error", err)
+	}
+}
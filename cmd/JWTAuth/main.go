@@ -3,6 +3,9 @@ package main
 import (
 	"github.com/ShlykovPavel/JWTAuth/auth"
 	"github.com/ShlykovPavel/JWTAuth/config"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/ShlykovPavel/JWTAuth/redact"
+	"github.com/ShlykovPavel/JWTAuth/tokenstore"
 	"log/slog"
 	"os"
 )
@@ -20,14 +23,28 @@ func main() {
 	log.Info("Starting application")
 	log.Debug("Debug messages enabled")
 
-	jwtauth := auth.NewJwtAuth(
-		"https://unuk-admin-stage.devol.xyz/api/accounts/login",
-		"https://unuk-admin-stage.devol.xyz/api/accounts/refresh-tokens",
-		cfg.Username,
-		cfg.Password,
-		cfg.RetryCount,
-		log)
-	err := jwtauth.Start()
+	opts := []auth.Option{
+		auth.WithEndpoints(
+			"https://unuk-admin-stage.devol.xyz/api/accounts/login",
+			"https://unuk-admin-stage.devol.xyz/api/accounts/refresh-tokens"),
+		auth.WithCredentials(cfg.Username, cfg.Password),
+		auth.WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: cfg.RetryCount + 1}),
+		auth.WithLogger(log),
+	}
+	if cfg.TokenCache != "" {
+		store, err := newTokenStore(cfg)
+		if err != nil {
+			log.Error("Error creating token store", "error", err.Error())
+			return
+		}
+		opts = append(opts, auth.WithTokenStore(store))
+	}
+	jwtauth, err := auth.New(opts...)
+	if err != nil {
+		log.Error("Error creating jwtauth", "error", err.Error())
+		return
+	}
+	err = jwtauth.Start()
 	if err != nil {
 		log.Error("Error starting jwtauth", "error", err.Error())
 	}
@@ -37,15 +54,32 @@ func main() {
 		return
 	}
 
-	log.Info("successfully got token", "token", token)
+	log.Info("successfully got token", slog.Any("token", redact.Fingerprint(token)))
 	select {}
 	//time.Sleep(time.Minute * 10)
 }
 
+// newTokenStore создаёт файловое хранилище токенов, зашифрованное, если задан файл ключа
+func newTokenStore(cfg *config.Config) (auth.TokenStore, error) {
+	if cfg.TokenCacheKeyFile == "" {
+		return tokenstore.NewFile(cfg.TokenCache), nil
+	}
+	key, err := tokenstore.KeyFromFile("default", cfg.TokenCacheKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	sealer, err := tokenstore.NewAEAD(tokenstore.AESGCM, key)
+	if err != nil {
+		return nil, err
+	}
+	return tokenstore.NewEncryptedFile(cfg.TokenCache, sealer), nil
+}
+
 // setupLogger
 //
 // Configures and initializes a structured logger (slog.Logger) tailored to the specified runtime environment.
 // The logger outputs log messages in JSON format, ensuring consistency and ease of parsing across different environments.
+// Secrets and tokens in log attributes are scrubbed by redact.Handler.
 //
 // Parameters:
 // - env (string): The runtime environment. Supported values:
@@ -67,13 +101,13 @@ func setupLogger(env string) *slog.Logger {
 	var log *slog.Logger
 	switch env {
 	case envLocal:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		log = slog.New(redact.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 
 	case envDev:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		log = slog.New(redact.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug})))
 
 	case envProd:
-		log = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+		log = slog.New(redact.NewHandler(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo})))
 
 	}
 	return log
@@ -12,11 +12,15 @@ import (
 // AUTH_REFRESH_URL  - URL для обновления токена
 // AUTH_USERNAME     - Логин сервисного аккаунта
 // AUTH_PASSWORD     - Пароль (не логируйте!)
+// AUTH_TOKEN_CACHE  - Файл для сохранения токенов между перезапусками, по умолчанию не используется
+// AUTH_TOKEN_CACHE_KEY_FILE - Файл с ключом шифрования AUTH_TOKEN_CACHE (32 байта или base64)
 type Config struct {
-	Env        string `env:"ENV" env-default:"production"`
-	Username   string `env:"AUTH_USERNAME" env-required:"true"`
-	Password   string `env:"AUTH_PASSWORD" env-required:"true"`
-	RetryCount int    `env:"AUTH_RETRY_COUNT" env-default:"3"`
+	Env               string `env:"ENV" env-default:"production"`
+	Username          string `env:"AUTH_USERNAME" env-required:"true"`
+	Password          string `env:"AUTH_PASSWORD" env-required:"true"`
+	RetryCount        int    `env:"AUTH_RETRY_COUNT" env-default:"3"`
+	TokenCache        string `env:"AUTH_TOKEN_CACHE" env-default:""`
+	TokenCacheKeyFile string `env:"AUTH_TOKEN_CACHE_KEY_FILE" env-default:""`
 }
 
 // LoadConfig Загрузка конфига
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Коды ошибок RFC 6750, раздел 3.1
+const (
+	ErrorInvalidRequest    = "invalid_request"
+	ErrorInvalidToken      = "invalid_token"
+	ErrorInsufficientScope = "insufficient_scope"
+)
+
+type claimsKey struct{}
+
+// ClaimsFromContext возвращает claims токена, проверенного Authenticate
+func ClaimsFromContext(ctx context.Context) (*JWTParser.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*JWTParser.Claims)
+	return claims, ok
+}
+
+type config struct {
+	realm  string
+	scopes []string
+	roles  []string
+	logger *slog.Logger
+}
+
+// Option настраивает Authenticate
+type Option func(*config)
+
+// WithRealm задаёт realm в заголовке WWW-Authenticate
+func WithRealm(realm string) Option {
+	return func(c *config) {
+		c.realm = realm
+	}
+}
+
+// WithRequiredScopes требует, чтобы токену были выданы все перечисленные scopes
+func WithRequiredScopes(scopes ...string) Option {
+	return func(c *config) {
+		c.scopes = scopes
+	}
+}
+
+// WithRequiredRoles требует, чтобы у владельца токена были все перечисленные роли
+func WithRequiredRoles(roles ...string) Option {
+	return func(c *config) {
+		c.roles = roles
+	}
+}
+
+// WithLogger задаёт логгер для отклонённых запросов, по умолчанию slog.Default()
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) {
+		c.logger = logger
+	}
+}
+
+// Authenticate возвращает middleware, которое принимает только запросы с валидным Bearer токеном в заголовке Authorization.
+// Подпись и claims exp, nbf, iss, aud проверяются verifier, затем проверяются обязательные scopes и роли.
+// Токены без claim exp отклоняются, даже если verifier создан без JWTParser.WithExpirationRequired.
+// Claims токена доступны обработчику через ClaimsFromContext.
+// Отказы возвращаются в формате RFC 6750: 401 без токена или с невалидным токеном,
+// 400 для некорректного заголовка и 403 при недостатке scopes или ролей.
+func Authenticate(verifier *JWTParser.Verifier, opts ...Option) func(http.Handler) http.Handler {
+	cfg := &config{logger: slog.Default()}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	log := cfg.logger.With(slog.String("op", "middleware.Authenticate"))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, err := bearerToken(r)
+			if err != nil {
+				log.Debug("request rejected", "error", err)
+				cfg.reject(w, http.StatusBadRequest, ErrorInvalidRequest, err.Error(), "")
+				return
+			}
+			if tokenString == "" {
+				cfg.reject(w, http.StatusUnauthorized, "", "", "")
+				return
+			}
+
+			claims := &JWTParser.Claims{}
+			if err := verifier.VerifyClaims(r.Context(), tokenString, claims); err != nil {
+				log.Debug("request rejected", "error", err)
+				cfg.reject(w, http.StatusUnauthorized, ErrorInvalidToken, describe(err), "")
+				return
+			}
+			// Без exp токен никогда не истекает: golang-jwt проверяет exp, только если он есть
+			if claims.ExpiresAt == nil {
+				log.Debug("request rejected", "error", "token has no exp claim", "sub", claims.Subject)
+				cfg.reject(w, http.StatusUnauthorized, ErrorInvalidToken, describe(jwt.ErrTokenRequiredClaimMissing), "")
+				return
+			}
+			for _, scope := range cfg.scopes {
+				if !claims.HasScope(scope) {
+					log.Debug("request rejected", "missing_scope", scope, "sub", claims.Subject)
+					cfg.reject(w, http.StatusForbidden, ErrorInsufficientScope, "missing scope "+scope, strings.Join(cfg.scopes, " "))
+					return
+				}
+			}
+			for _, role := range cfg.roles {
+				if !claims.HasRole(role) {
+					log.Debug("request rejected", "missing_role", role, "sub", claims.Subject)
+					cfg.reject(w, http.StatusForbidden, ErrorInsufficientScope, "missing role "+role, "")
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimsKey{}, claims)))
+		})
+	}
+}
+
+// bearerToken извлекает токен из заголовка Authorization. Пустая строка без ошибки - заголовка нет
+func bearerToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", nil
+	}
+	if len(r.Header.Values("Authorization")) > 1 {
+		return "", errors.New("multiple authorization headers")
+	}
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		// Другие схемы аутентификации для этого ресурса не поддерживаются
+		return "", nil
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", errors.New("empty bearer token")
+	}
+	return token, nil
+}
+
+// reject отвечает ошибкой с заголовком WWW-Authenticate (RFC 6750, раздел 3).
+// scope передаётся только при недостатке scopes, чтобы не предлагать клиенту запросить уже выданные
+func (c *config) reject(w http.ResponseWriter, status int, code, description, scope string) {
+	var params []string
+	if c.realm != "" {
+		params = append(params, fmt.Sprintf("realm=%q", c.realm))
+	}
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code))
+	}
+	if description != "" {
+		params = append(params, fmt.Sprintf("error_description=%q", description))
+	}
+	if scope != "" {
+		params = append(params, fmt.Sprintf("scope=%q", scope))
+	}
+	challenge := "Bearer"
+	if len(params) > 0 {
+		challenge += " " + strings.Join(params, ", ")
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// describe возвращает описание ошибки без деталей ключей и значений claims
+func describe(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		return "token not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "invalid issuer"
+	case errors.Is(err, jwt.ErrTokenInvalidAudience):
+		return "invalid audience"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "missing exp claim"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "malformed token"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, JWTParser.ErrKeyNotFound):
+		return "invalid signature"
+	}
+	return "invalid token"
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"github.com/ShlykovPavel/JWTAuth/JWTParser"
+	"github.com/golang-jwt/jwt/v5"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, mutate func(jwt.MapClaims)) string {
+	t.Helper()
+	claims := jwt.MapClaims{
+		"sub":   "service-a",
+		"iss":   "https://idp.example",
+		"aud":   "api",
+		"scope": "orders:read orders:write",
+		"roles": []string{"admin"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	if mutate != nil {
+		mutate(claims)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := JWTParser.NewStaticKeySet(JWTParser.Key{Key: []byte("test-secret")})
+	verifier := JWTParser.NewVerifier(keys, JWTParser.WithIssuer("https://idp.example"), JWTParser.WithAudience("api"))
+	handler := Authenticate(verifier,
+		WithRealm("orders"),
+		WithRequiredScopes("orders:read"),
+		WithRequiredRoles("admin"),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			t.Error("claims not found in context")
+			return
+		}
+		io.WriteString(w, claims.Subject)
+	}))
+
+	tests := []struct {
+		testName      string
+		authorization string
+		wantStatus    int
+		wantChallenge string
+	}{
+		{"PositiveValidToken", "Bearer " + signToken(t, nil), http.StatusOK, ""},
+		{"PositiveSchemeCaseInsensitive", "bearer " + signToken(t, nil), http.StatusOK, ""},
+		{"NegativeNoToken", "", http.StatusUnauthorized, `Bearer realm="orders"`},
+		{"NegativeOtherScheme", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, `Bearer realm="orders"`},
+		{"NegativeEmptyToken", "Bearer ", http.StatusBadRequest, `Bearer realm="orders", error="invalid_request", error_description="empty bearer token"`},
+		{"NegativeMalformed", "Bearer abc", http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="malformed token"`},
+		{"NegativeExpired",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }),
+			http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="token expired"`},
+		{"NegativeNotYetValid",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }),
+			http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="token not valid yet"`},
+		{"NegativeWrongIssuer",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }),
+			http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="invalid issuer"`},
+		{"NegativeWrongAudience",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { c["aud"] = "web" }),
+			http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="invalid audience"`},
+		{"NegativeMissingScope",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { c["scope"] = "orders:write" }),
+			http.StatusForbidden, `Bearer realm="orders", error="insufficient_scope", error_description="missing scope orders:read", scope="orders:read"`},
+		{"NegativeMissingRole",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { c["roles"] = "viewer" }),
+			http.StatusForbidden, `Bearer realm="orders", error="insufficient_scope", error_description="missing role admin"`},
+		{"NegativeNoExpiration",
+			"Bearer " + signToken(t, func(c jwt.MapClaims) { delete(c, "exp") }),
+			http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token", error_description="missing exp claim"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("got challenge %s, want %s", got, tt.wantChallenge)
+			}
+			if tt.wantStatus == http.StatusOK && strings.TrimSpace(rec.Body.String()) != "service-a" {
+				t.Errorf("got body %q, want subject", rec.Body.String())
+			}
+		})
+	}
+}
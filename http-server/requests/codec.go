@@ -0,0 +1,212 @@
+package requests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TokenEndpointCodec описывает формат эндпоинтов логина и обновления токенов:
+// как построить запрос и как достать токены из успешного ответа.
+// Позволяет работать с сервисами авторизации, у которых другие имена полей,
+// form-encoding, вложенные объекты или токены в cookie.
+type TokenEndpointCodec interface {
+	// NewLoginRequest строит запрос логина. Вызывается для каждой попытки заново.
+	NewLoginRequest(ctx context.Context, URL string, credentials Credentials) (*http.Request, error)
+	// NewRefreshRequest строит запрос обновления токенов. Вызывается для каждой попытки заново.
+	NewRefreshRequest(ctx context.Context, URL string, tokens Tokens) (*http.Request, error)
+	// DecodeTokens достаёт токены из ответа со статусом 200
+	DecodeTokens(resp *http.Response) (*Tokens, error)
+}
+
+// secretHolder реализуют кодеки, добавляющие в запросы собственные секреты.
+// Эти значения скрываются в логах вместе с паролем и токенами.
+type secretHolder interface {
+	secretValues() []string
+}
+
+// JSONCodec - формат по умолчанию: JSON тело с полями accessKey/secretKey для логина
+// и accessToken/refreshToken для обновления и в ответе
+type JSONCodec struct{}
+
+func (JSONCodec) NewLoginRequest(ctx context.Context, URL string, credentials Credentials) (*http.Request, error) {
+	return newJSONRequest(ctx, URL, credentials)
+}
+
+func (JSONCodec) NewRefreshRequest(ctx context.Context, URL string, tokens Tokens) (*http.Request, error) {
+	return newJSONRequest(ctx, URL, tokens)
+}
+
+func (JSONCodec) DecodeTokens(resp *http.Response) (*Tokens, error) {
+	var tokens Tokens
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("decode tokens: %w", err)
+	}
+	return &tokens, nil
+}
+
+// JSONPathCodec - настраиваемый формат эндпоинтов.
+// Пути к полям задаются через точку, например "data.tokens.access" соответствует
+// {"data": {"tokens": {"access": "..."}}}. Пустой путь означает, что поле не передаётся или не читается.
+type JSONPathCodec struct {
+	// UsernameField и PasswordField - пути к логину и паролю в теле запроса логина
+	UsernameField string
+	PasswordField string
+	// RefreshTokenField и AccessTokenField - пути к токенам в теле запроса обновления
+	RefreshTokenField string
+	AccessTokenField  string
+	// ExtraFields - постоянные поля, добавляемые в тело обоих запросов (например, client_id).
+	// Их значения не попадают в логи, поэтому здесь можно передавать секреты клиента под любыми именами.
+	ExtraFields map[string]string
+	// Form - отправлять тело как application/x-www-form-urlencoded вместо JSON.
+	// В этом режиме путь используется как имя поля целиком.
+	Form bool
+
+	// AccessTokenPath и RefreshTokenPath - пути к токенам в JSON ответе
+	AccessTokenPath  string
+	RefreshTokenPath string
+	// AccessTokenCookie - имя cookie, из которой берётся access токен, если он не найден в теле ответа
+	AccessTokenCookie string
+	// RefreshTokenCookie - имя cookie с refresh токеном: токен читается из Set-Cookie ответа,
+	// если не найден в теле, и отправляется в этой cookie в запросе обновления
+	RefreshTokenCookie string
+}
+
+func (c JSONPathCodec) NewLoginRequest(ctx context.Context, URL string, credentials Credentials) (*http.Request, error) {
+	return c.newRequest(ctx, URL, map[string]string{
+		c.UsernameField: credentials.Username,
+		c.PasswordField: credentials.Password,
+	})
+}
+
+func (c JSONPathCodec) NewRefreshRequest(ctx context.Context, URL string, tokens Tokens) (*http.Request, error) {
+	fields := map[string]string{
+		c.RefreshTokenField: tokens.RefreshToken,
+		c.AccessTokenField:  tokens.AccessToken,
+	}
+	req, err := c.newRequest(ctx, URL, fields)
+	if err != nil {
+		return nil, err
+	}
+	if c.RefreshTokenCookie != "" {
+		req.AddCookie(&http.Cookie{Name: c.RefreshTokenCookie, Value: tokens.RefreshToken})
+	}
+	return req, nil
+}
+
+func (c JSONPathCodec) DecodeTokens(resp *http.Response) (*Tokens, error) {
+	var body any
+	if c.AccessTokenPath != "" || c.RefreshTokenPath != "" {
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("decode tokens: %w", err)
+		}
+	}
+
+	tokens := &Tokens{
+		AccessToken:  lookupPath(body, c.AccessTokenPath),
+		RefreshToken: lookupPath(body, c.RefreshTokenPath),
+	}
+	for _, cookie := range resp.Cookies() {
+		switch {
+		case tokens.AccessToken == "" && c.AccessTokenCookie != "" && cookie.Name == c.AccessTokenCookie:
+			tokens.AccessToken = cookie.Value
+		case tokens.RefreshToken == "" && c.RefreshTokenCookie != "" && cookie.Name == c.RefreshTokenCookie:
+			tokens.RefreshToken = cookie.Value
+		}
+	}
+	if tokens.AccessToken == "" {
+		return nil, fmt.Errorf("decode tokens: access token not found in response")
+	}
+	return tokens, nil
+}
+
+func (c JSONPathCodec) secretValues() []string {
+	values := make([]string, 0, len(c.ExtraFields))
+	for _, value := range c.ExtraFields {
+		values = append(values, value)
+	}
+	return values
+}
+
+// newRequest строит тело из полей, пропуская поля с пустым путём
+func (c JSONPathCodec) newRequest(ctx context.Context, URL string, fields map[string]string) (*http.Request, error) {
+	all := make(map[string]string, len(fields)+len(c.ExtraFields))
+	for path, value := range c.ExtraFields {
+		all[path] = value
+	}
+	for path, value := range fields {
+		if path != "" {
+			all[path] = value
+		}
+	}
+
+	if c.Form {
+		form := url.Values{}
+		for name, value := range all {
+			form.Set(name, value)
+		}
+		return newFormRequest(ctx, URL, form)
+	}
+
+	body := map[string]any{}
+	for path, value := range all {
+		setPath(body, path, value)
+	}
+	return newJSONRequest(ctx, URL, body)
+}
+
+// setPath записывает значение по пути через точку, создавая вложенные объекты
+func setPath(body map[string]any, path string, value string) {
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := body[key].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			body[key] = next
+		}
+		body = next
+	}
+	body[keys[len(keys)-1]] = value
+}
+
+// lookupPath достаёт строковое значение по пути через точку, возвращает "" если его нет
+func lookupPath(body any, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		object, ok := body.(map[string]any)
+		if !ok {
+			return ""
+		}
+		body = object[key]
+	}
+	value, _ := body.(string)
+	return value
+}
+
+func newJSONRequest(ctx context.Context, URL string, body any) (*http.Request, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("encode request body: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, URL, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func newFormRequest(ctx context.Context, URL string, form url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
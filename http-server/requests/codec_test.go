@@ -0,0 +1,129 @@
+package requests
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONPathCodec(t *testing.T) {
+	// Сервер проверяет тело запроса и отвечает в заданном формате
+	var gotBody, gotContentType, gotCookie string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		gotContentType = r.Header.Get("Content-Type")
+		if cookie, err := r.Cookie("rt"); err == nil {
+			gotCookie = cookie.Value
+		}
+		switch r.URL.Path {
+		case "/nested":
+			w.Write([]byte(`{"data": {"tokens": {"access": "access-1", "refresh": "refresh-1"}}}`))
+		case "/cookie":
+			http.SetCookie(w, &http.Cookie{Name: "rt", Value: "refresh-cookie"})
+			w.Write([]byte(`{"token": "access-2"}`))
+		case "/empty":
+			w.Write([]byte(`{"data": {}}`))
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		testName        string
+		path            string
+		codec           JSONPathCodec
+		refresh         bool
+		wantBody        string
+		wantContentType string
+		wantCookie      string
+		wantTokens      Tokens
+		wantError       bool
+	}{
+		{
+			testName: "NestedJSONLogin",
+			path:     "/nested",
+			codec: JSONPathCodec{
+				UsernameField:    "auth.login",
+				PasswordField:    "auth.password",
+				AccessTokenPath:  "data.tokens.access",
+				RefreshTokenPath: "data.tokens.refresh",
+			},
+			wantBody:        `{"auth":{"login":"user","password":"secret"}}`,
+			wantContentType: "application/json",
+			wantTokens:      Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"},
+		},
+		{
+			testName: "FormLoginWithExtraFields",
+			path:     "/nested",
+			codec: JSONPathCodec{
+				UsernameField:    "username",
+				PasswordField:    "password",
+				ExtraFields:      map[string]string{"client_id": "app"},
+				Form:             true,
+				AccessTokenPath:  "data.tokens.access",
+				RefreshTokenPath: "data.tokens.refresh",
+			},
+			wantBody:        "client_id=app&password=secret&username=user",
+			wantContentType: "application/x-www-form-urlencoded",
+			wantTokens:      Tokens{AccessToken: "access-1", RefreshToken: "refresh-1"},
+		},
+		{
+			testName: "RefreshTokenInCookie",
+			path:     "/cookie",
+			codec: JSONPathCodec{
+				AccessTokenField:   "token",
+				AccessTokenPath:    "token",
+				RefreshTokenCookie: "rt",
+			},
+			refresh:         true,
+			wantBody:        `{"token":"old-access"}`,
+			wantContentType: "application/json",
+			wantCookie:      "old-refresh",
+			wantTokens:      Tokens{AccessToken: "access-2", RefreshToken: "refresh-cookie"},
+		},
+		{
+			testName:  "NegativeAccessTokenMissing",
+			path:      "/empty",
+			codec:     JSONPathCodec{AccessTokenPath: "data.access"},
+			wantError: true,
+		},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			gotBody, gotContentType, gotCookie = "", "", ""
+			client := NewClient(nil, WithCodec(tt.codec))
+			var tokens *Tokens
+			var err error
+			if tt.refresh {
+				tokens, err = client.Refresh(context.Background(), testServer.URL+tt.path, Tokens{AccessToken: "old-access", RefreshToken: "old-refresh"}, log)
+			} else {
+				tokens, err = client.Login(context.Background(), testServer.URL+tt.path, Credentials{Username: "user", Password: "secret"}, log)
+			}
+			if tt.wantError {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotBody != tt.wantBody {
+				t.Errorf("got request body %s, want %s", gotBody, tt.wantBody)
+			}
+			if gotContentType != tt.wantContentType {
+				t.Errorf("got content type %s, want %s", gotContentType, tt.wantContentType)
+			}
+			if gotCookie != tt.wantCookie {
+				t.Errorf("got refresh cookie %q, want %q", gotCookie, tt.wantCookie)
+			}
+			if *tokens != tt.wantTokens {
+				t.Errorf("got tokens %+v, want %+v", *tokens, tt.wantTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package requests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Классы ошибок аутентификации. Проверяются через errors.Is на ошибках Client и LoginOrRefreshInService.
+var (
+	// ErrInvalidCredentials сервер отклонил логин: неверные учётные данные пользователя или клиента
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrRefreshTokenExpired сервер отклонил refresh токен: он истёк или отозван, нужен повторный логин
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	// ErrServerUnavailable сервер авторизации недоступен или отвечает 5xx/429
+	ErrServerUnavailable = errors.New("auth server unavailable")
+	// ErrTimeout истёк таймаут запроса к серверу авторизации
+	ErrTimeout = errors.New("request timeout")
+)
+
+// HTTPError - ответ сервера авторизации с неуспешным статусом после всех попыток
+type HTTPError struct {
+	// Operation "login" или "refresh"
+	Operation string
+	// StatusCode статус последнего ответа
+	StatusCode int
+	// Body тело последнего ответа, секреты в нём скрыты так же, как в логах (см. redact.Body)
+	Body string
+	// Attempts сколько всего попыток было выполнено
+	Attempts int
+	// Err разобранная ошибка сервера (например, *OAuth2Error), может быть nil
+	Err error
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("%s failed after %d attempts: status %d", e.Operation, e.Attempts, e.StatusCode)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap возвращает разобранную ошибку сервера и класс ошибки (ErrInvalidCredentials и т.д.)
+func (e *HTTPError) Unwrap() []error {
+	errs := make([]error, 0, 2)
+	if e.Err != nil {
+		errs = append(errs, e.Err)
+	}
+	if class := e.class(); class != nil {
+		errs = append(errs, class)
+	}
+	return errs
+}
+
+// class определяет класс ошибки по статусу ответа и коду ошибки OAuth2
+func (e *HTTPError) class() error {
+	var oauthErr *OAuth2Error
+	if errors.As(e.Err, &oauthErr) {
+		switch {
+		case errors.Is(oauthErr, ErrInvalidClient):
+			return ErrInvalidCredentials
+		case errors.Is(oauthErr, ErrInvalidGrant) && e.Operation == "refresh":
+			return ErrRefreshTokenExpired
+		case errors.Is(oauthErr, ErrInvalidGrant):
+			return ErrInvalidCredentials
+		}
+	}
+	switch {
+	case e.StatusCode == http.StatusBadRequest, e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		if e.Operation == "refresh" {
+			return ErrRefreshTokenExpired
+		}
+		return ErrInvalidCredentials
+	case e.StatusCode == http.StatusTooManyRequests, e.StatusCode >= http.StatusInternalServerError:
+		return ErrServerUnavailable
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package requests
 
 import (
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 	"net/http"
 	"time"
 )
 
+// TracerName - имя инструментирования в спанах OpenTelemetry
+const TracerName = "github.com/ShlykovPavel/JWTAuth"
+
 var (
 	defaultClient = &http.Client{
 		Timeout: time.Second * 10,
@@ -15,3 +22,87 @@ var (
 		},
 	}
 )
+
+// Client выполняет запросы логина и обновления токенов
+type Client struct {
+	httpClient *http.Client
+	retry      RetryPolicy
+	codec      TokenEndpointCodec
+	clock      clock.Clock
+	metrics    Metrics
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// ClientOption настраивает Client
+type ClientOption func(*Client)
+
+// WithRetryPolicy задаёт политику повторов. По умолчанию ExponentialBackoff с параметрами по умолчанию.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		if policy != nil {
+			c.retry = policy
+		}
+	}
+}
+
+// WithCodec задаёт формат эндпоинтов логина и обновления. По умолчанию JSONCodec.
+func WithCodec(codec TokenEndpointCodec) ClientOption {
+	return func(c *Client) {
+		if codec != nil {
+			c.codec = codec
+		}
+	}
+}
+
+// WithClock задаёт источник времени для срока действия токенов (ExpiresAt) и ожидания между попытками.
+// По умолчанию clock.System. По этим часам ExponentialBackoff отсчитывает Retry-After в виде HTTP-даты.
+// Таймауты HTTP клиента и RetryPolicy.Deadline отсчитываются по системному времени.
+func WithClock(clk clock.Clock) ClientOption {
+	return func(c *Client) {
+		if clk != nil {
+			c.clock = clk
+		}
+	}
+}
+
+// WithMetrics задаёт получателя метрик запросов, по умолчанию метрики не собираются
+func WithMetrics(m Metrics) ClientOption {
+	return func(c *Client) {
+		if m != nil {
+			c.metrics = m
+		}
+	}
+}
+
+// WithTracerProvider включает трассировку: span на каждую операцию логина или обновления и на каждую попытку HTTP запроса.
+// Контекст трассировки передаётся сервису авторизации в заголовках W3C Trace Context (traceparent).
+// По умолчанию трассировка выключена.
+func WithTracerProvider(tp trace.TracerProvider) ClientOption {
+	return func(c *Client) {
+		if tp != nil {
+			c.tracer = tp.Tracer(TracerName)
+		}
+	}
+}
+
+// NewClient создаёт клиент для запросов к сервису авторизации.
+// Если httpClient равен nil, используется клиент по умолчанию с таймаутом 10 секунд.
+func NewClient(httpClient *http.Client, opts ...ClientOption) *Client {
+	if httpClient == nil {
+		httpClient = defaultClient
+	}
+	c := &Client{
+		httpClient: httpClient,
+		retry:      ExponentialBackoff{},
+		codec:      JSONCodec{},
+		clock:      clock.System,
+		metrics:    noopMetrics{},
+		tracer:     noop.NewTracerProvider().Tracer(TracerName),
+		propagator: propagation.TraceContext{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
@@ -0,0 +1,19 @@
+package requests
+
+import "time"
+
+// Metrics получает сведения о запросах к сервису авторизации, например для Prometheus (пакет metrics).
+// operation - "login" или "refresh", status - код ответа или 0, если ответ не получен.
+type Metrics interface {
+	// ObserveAttempt вызывается после каждой попытки запроса с её длительностью
+	ObserveAttempt(operation string, status int, duration time.Duration)
+	// ObserveResult вызывается по завершении операции со всеми попытками.
+	// status - код последнего ответа, err - итоговая ошибка или nil.
+	ObserveResult(operation string, status int, attempts int, err error)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) ObserveAttempt(string, int, time.Duration) {}
+
+func (noopMetrics) ObserveResult(string, int, int, error) {}
@@ -0,0 +1,217 @@
+package requests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrRefreshNotSupported возвращается кодеком, если формат не поддерживает обновление токенов.
+// В этом случае JWTAuth получает новые токены повторным логином.
+var ErrRefreshNotSupported = errors.New("refresh is not supported by token endpoint")
+
+// Коды ошибок token endpoint (RFC 6749, 5.2). Проверяются через errors.Is на *OAuth2Error.
+var (
+	ErrInvalidRequest       = errors.New("invalid_request")
+	ErrInvalidClient        = errors.New("invalid_client")
+	ErrInvalidGrant         = errors.New("invalid_grant")
+	ErrUnauthorizedClient   = errors.New("unauthorized_client")
+	ErrUnsupportedGrantType = errors.New("unsupported_grant_type")
+	ErrInvalidScope         = errors.New("invalid_scope")
+)
+
+var oauth2ErrorCodes = map[string]error{
+	ErrInvalidRequest.Error():       ErrInvalidRequest,
+	ErrInvalidClient.Error():        ErrInvalidClient,
+	ErrInvalidGrant.Error():         ErrInvalidGrant,
+	ErrUnauthorizedClient.Error():   ErrUnauthorizedClient,
+	ErrUnsupportedGrantType.Error(): ErrUnsupportedGrantType,
+	ErrInvalidScope.Error():         ErrInvalidScope,
+}
+
+// OAuth2Error - ответ token endpoint с ошибкой (RFC 6749, 5.2)
+type OAuth2Error struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+func (e *OAuth2Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: %s: %s (status %d)", e.Code, e.Description, e.StatusCode)
+	}
+	return fmt.Sprintf("oauth2: %s (status %d)", e.Code, e.StatusCode)
+}
+
+// Is позволяет сравнивать ошибку с ErrInvalidGrant, ErrInvalidClient и другими кодами через errors.Is
+func (e *OAuth2Error) Is(target error) bool {
+	code, ok := oauth2ErrorCodes[e.Code]
+	return ok && code == target
+}
+
+// ErrorDecoder - необязательный интерфейс кодека для разбора ответов с ошибкой.
+// Ошибка DecodeError уточняет класс ошибки: она сохраняется в HTTPError.Err и доступна через errors.As и errors.Is
+// на возвращённой *HTTPError. На повторы она не влияет: решение о повторе принимает RetryPolicy по коду ответа.
+type ErrorDecoder interface {
+	DecodeError(resp *http.Response, body []byte) error
+}
+
+// AuthStyle - способ передачи client_id и client_secret в OAuth2 запросах
+type AuthStyle int
+
+const (
+	// AuthStyleInHeader передаёт учётные данные клиента в заголовке Authorization: Basic (RFC 6749, 2.3.1)
+	AuthStyleInHeader AuthStyle = iota
+	// AuthStyleInParams передаёт client_id и client_secret в теле запроса
+	AuthStyleInParams
+)
+
+// ClientCredentialsCodec - OAuth2 client_credentials grant (RFC 6749, 4.4).
+// Username из Credentials используется как client_id, Password - как client_secret.
+// Обычно сервер не выдаёт refresh токен, поэтому по истечении токена grant выполняется заново.
+type ClientCredentialsCodec struct {
+	// Scopes запрашиваемые права, передаются через пробел в параметре scope
+	Scopes []string
+	// Audience параметр audience (расширение Auth0, Okta и др.), не передаётся, если пуст
+	Audience string
+	// AuthStyle способ передачи учётных данных клиента
+	AuthStyle AuthStyle
+}
+
+func (c ClientCredentialsCodec) NewLoginRequest(ctx context.Context, URL string, credentials Credentials) (*http.Request, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.Audience != "" {
+		form.Set("audience", c.Audience)
+	}
+	return newOAuth2Request(ctx, URL, form, credentials, c.AuthStyle)
+}
+
+func (ClientCredentialsCodec) NewRefreshRequest(context.Context, string, Tokens) (*http.Request, error) {
+	return nil, ErrRefreshNotSupported
+}
+
+func (ClientCredentialsCodec) DecodeTokens(resp *http.Response) (*Tokens, error) {
+	return decodeOAuth2Tokens(resp)
+}
+
+func (ClientCredentialsCodec) DecodeError(resp *http.Response, body []byte) error {
+	return decodeOAuth2Error(resp, body)
+}
+
+// PasswordGrantCodec - OAuth2 password grant (RFC 6749, 4.3) для логина
+// и refresh_token grant (RFC 6749, 6) для обновления.
+// Логин и пароль пользователя берутся из Credentials, данные клиента - из ClientID и ClientSecret.
+// Если при обновлении сервер не вернул новый refresh токен, сохраняется прежний.
+type PasswordGrantCodec struct {
+	// ClientID идентификатор клиента. Если пуст, клиент не аутентифицируется (public client)
+	ClientID string
+	// ClientSecret секрет клиента, может быть пустым для public client
+	ClientSecret string
+	// Scopes запрашиваемые права, передаются через пробел в параметре scope
+	Scopes []string
+	// AuthStyle способ передачи учётных данных клиента
+	AuthStyle AuthStyle
+}
+
+func (c PasswordGrantCodec) NewLoginRequest(ctx context.Context, URL string, credentials Credentials) (*http.Request, error) {
+	form := url.Values{
+		"grant_type": {"password"},
+		"username":   {credentials.Username},
+		"password":   {credentials.Password},
+	}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	return newOAuth2Request(ctx, URL, form, c.client(), c.AuthStyle)
+}
+
+func (c PasswordGrantCodec) NewRefreshRequest(ctx context.Context, URL string, tokens Tokens) (*http.Request, error) {
+	if tokens.RefreshToken == "" {
+		return nil, ErrRefreshNotSupported
+	}
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tokens.RefreshToken},
+	}
+	return newOAuth2Request(ctx, URL, form, c.client(), c.AuthStyle)
+}
+
+func (PasswordGrantCodec) DecodeTokens(resp *http.Response) (*Tokens, error) {
+	return decodeOAuth2Tokens(resp)
+}
+
+func (PasswordGrantCodec) DecodeError(resp *http.Response, body []byte) error {
+	return decodeOAuth2Error(resp, body)
+}
+
+func (c PasswordGrantCodec) secretValues() []string {
+	return []string{c.ClientSecret}
+}
+
+func (c PasswordGrantCodec) client() Credentials {
+	return Credentials{Username: c.ClientID, Password: c.ClientSecret}
+}
+
+// oauth2TokenResponse - успешный ответ token endpoint (RFC 6749, 5.1)
+type oauth2TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func decodeOAuth2Tokens(resp *http.Response) (*Tokens, error) {
+	var body oauth2TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decode tokens: %w", err)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("decode tokens: access_token is missing")
+	}
+	return &Tokens{
+		AccessToken:  body.AccessToken,
+		RefreshToken: body.RefreshToken,
+		TokenType:    body.TokenType,
+		ExpiresIn:    time.Duration(body.ExpiresIn) * time.Second,
+	}, nil
+}
+
+// decodeOAuth2Error разбирает тело ошибки token endpoint.
+// Возвращает nil, если тело не является ошибкой в формате RFC 6749.
+func decodeOAuth2Error(resp *http.Response, body []byte) error {
+	oauthErr := &OAuth2Error{StatusCode: resp.StatusCode}
+	if err := json.Unmarshal(body, oauthErr); err != nil || oauthErr.Code == "" {
+		return nil
+	}
+	return oauthErr
+}
+
+// newOAuth2Request строит form-encoded запрос к token endpoint с аутентификацией клиента.
+// Если client.Username пуст, клиент не аутентифицируется.
+func newOAuth2Request(ctx context.Context, URL string, form url.Values, client Credentials, style AuthStyle) (*http.Request, error) {
+	authenticate := client.Username != ""
+	if authenticate && style == AuthStyleInParams {
+		form.Set("client_id", client.Username)
+		if client.Password != "" {
+			form.Set("client_secret", client.Password)
+		}
+	}
+	req, err := newFormRequest(ctx, URL, form)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if authenticate && style == AuthStyleInHeader {
+		req.SetBasicAuth(url.QueryEscape(client.Username), url.QueryEscape(client.Password))
+	}
+	return req, nil
+}
@@ -0,0 +1,219 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientCredentialsCodec(t *testing.T) {
+	var gotForm map[string]string
+	var gotUser, gotPassword string
+	var gotBasic bool
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		gotForm = map[string]string{}
+		for key := range r.PostForm {
+			gotForm[key] = r.PostForm.Get(key)
+		}
+		gotUser, gotPassword, gotBasic = r.BasicAuth()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token": "opaque", "token_type": "Bearer", "expires_in": 300}`))
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		testName  string
+		codec     ClientCredentialsCodec
+		wantForm  map[string]string
+		wantBasic bool
+	}{
+		{
+			testName:  "BasicAuthWithScopes",
+			codec:     ClientCredentialsCodec{Scopes: []string{"read", "write"}, Audience: "api"},
+			wantForm:  map[string]string{"grant_type": "client_credentials", "scope": "read write", "audience": "api"},
+			wantBasic: true,
+		},
+		{
+			testName: "CredentialsInBody",
+			codec:    ClientCredentialsCodec{AuthStyle: AuthStyleInParams},
+			wantForm: map[string]string{"grant_type": "client_credentials", "client_id": "client", "client_secret": "s3cr:et"},
+		},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			client := NewClient(nil, WithCodec(tt.codec))
+			started := time.Now()
+			tokens, err := client.Login(context.Background(), testServer.URL, Credentials{Username: "client", Password: "s3cr:et"}, log)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(gotForm) != len(tt.wantForm) {
+				t.Errorf("got form %v, want %v", gotForm, tt.wantForm)
+			}
+			for key, want := range tt.wantForm {
+				if gotForm[key] != want {
+					t.Errorf("got %s=%q, want %q", key, gotForm[key], want)
+				}
+			}
+			if gotBasic != tt.wantBasic {
+				t.Errorf("got basic auth %v, want %v", gotBasic, tt.wantBasic)
+			}
+			// Спецсимволы в client_secret кодируются перед Basic auth (RFC 6749, 2.3.1)
+			if tt.wantBasic && (gotUser != "client" || gotPassword != "s3cr%3Aet") {
+				t.Errorf("got basic auth %q:%q", gotUser, gotPassword)
+			}
+			if tokens.AccessToken != "opaque" || tokens.TokenType != "Bearer" || tokens.ExpiresIn != 5*time.Minute {
+				t.Errorf("got tokens %+v", tokens)
+			}
+			if tokens.ExpiresAt.Before(started.Add(5 * time.Minute)) {
+				t.Errorf("got expiry %v, want at least %v", tokens.ExpiresAt, started.Add(5*time.Minute))
+			}
+
+			_, err = client.Refresh(context.Background(), testServer.URL, *tokens, log)
+			if !errors.Is(err, ErrRefreshNotSupported) {
+				t.Errorf("expected ErrRefreshNotSupported, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPasswordGrantCodec(t *testing.T) {
+	var calls int
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		r.ParseForm()
+		if user, _, ok := r.BasicAuth(); !ok || user != "app" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid_client"}`))
+			return
+		}
+		switch r.PostForm.Get("grant_type") + ":" + r.PostForm.Get("username") + r.PostForm.Get("refresh_token") {
+		case "password:user":
+			w.Write([]byte(`{"access_token": "access-1", "refresh_token": "refresh-1", "expires_in": 60}`))
+		case "refresh_token:rotate":
+			w.Write([]byte(`{"access_token": "access-2", "refresh_token": "refresh-2"}`))
+		case "refresh_token:keep":
+			w.Write([]byte(`{"access_token": "access-3"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant", "error_description": "refresh token revoked"}`))
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		testName     string
+		clientID     string
+		refreshToken string
+		wantTokens   Tokens
+		wantError    error
+		wantClass    error
+	}{
+		{"PasswordLogin", "app", "", Tokens{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresIn: time.Minute}, nil, nil},
+		{"RefreshWithRotation", "app", "rotate", Tokens{AccessToken: "access-2", RefreshToken: "refresh-2"}, nil, nil},
+		{"RefreshKeepsOldToken", "app", "keep", Tokens{AccessToken: "access-3", RefreshToken: "keep"}, nil, nil},
+		{"NegativeInvalidGrant", "app", "revoked", Tokens{}, ErrInvalidGrant, ErrRefreshTokenExpired},
+		{"NegativeInvalidClient", "other", "", Tokens{}, ErrInvalidClient, ErrInvalidCredentials},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			calls = 0
+			client := NewClient(nil,
+				WithCodec(PasswordGrantCodec{ClientID: tt.clientID, ClientSecret: "secret"}),
+				WithRetryPolicy(LinearRetry{Count: 3}))
+			var tokens *Tokens
+			var err error
+			if tt.refreshToken != "" {
+				tokens, err = client.Refresh(context.Background(), testServer.URL, Tokens{AccessToken: "old", RefreshToken: tt.refreshToken}, log)
+			} else {
+				tokens, err = client.Login(context.Background(), testServer.URL, Credentials{Username: "user", Password: "password"}, log)
+			}
+			if tt.wantError != nil {
+				if !errors.Is(err, tt.wantError) {
+					t.Fatalf("expected %v, got %v", tt.wantError, err)
+				}
+				if !errors.Is(err, tt.wantClass) {
+					t.Errorf("expected %v, got %v", tt.wantClass, err)
+				}
+				var oauthErr *OAuth2Error
+				if !errors.As(err, &oauthErr) || oauthErr.StatusCode < 400 {
+					t.Errorf("expected *OAuth2Error with status, got %#v", err)
+				}
+				// Ответы 400 и 401 с ошибкой RFC 6749 окончательные, повторять запрос бессмысленно
+				if calls != 1 {
+					t.Errorf("got %d calls, want 1", calls)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tokens.ExpiresAt = time.Time{}
+			if *tokens != tt.wantTokens {
+				t.Errorf("got tokens %+v, want %+v", *tokens, tt.wantTokens)
+			}
+		})
+	}
+}
+
+func TestOAuth2RetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "temporarily_unavailable"}`))
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": "slow_down"}`))
+		default:
+			w.Write([]byte(`{"access_token": "access", "refresh_token": "refresh"}`))
+		}
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		testName string
+		codec    TokenEndpointCodec
+	}{
+		{"ClientCredentials", ClientCredentialsCodec{}},
+		{"PasswordGrant", PasswordGrantCodec{ClientID: "app"}},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			calls.Store(0)
+			client := NewClient(nil,
+				WithCodec(tt.codec),
+				WithRetryPolicy(ExponentialBackoff{MaxAttempts: 3, InitialInterval: time.Millisecond}))
+			tokens, err := client.Login(context.Background(), testServer.URL, Credentials{Username: "user", Password: "password"}, log)
+			if err != nil {
+				t.Fatalf("Login failed: %v", err)
+			}
+			if tokens.AccessToken != "access" || calls.Load() != 3 {
+				t.Errorf("got token %q after %d calls, want access after 3", tokens.AccessToken, calls.Load())
+			}
+		})
+	}
+
+	// Если попытки исчерпаны, возвращается разобранная ошибка последнего ответа
+	calls.Store(0)
+	client := NewClient(nil,
+		WithCodec(ClientCredentialsCodec{}),
+		WithRetryPolicy(ExponentialBackoff{MaxAttempts: 1}))
+	_, err := client.Login(context.Background(), testServer.URL, Credentials{Username: "user", Password: "password"}, log)
+	var oauthErr *OAuth2Error
+	if !errors.Is(err, ErrServerUnavailable) || !errors.As(err, &oauthErr) || oauthErr.Code != "temporarily_unavailable" {
+		t.Errorf("got %v, want ErrServerUnavailable with OAuth2 error", err)
+	}
+}
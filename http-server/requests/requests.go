@@ -1,9 +1,14 @@
 package requests
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"github.com/ShlykovPavel/JWTAuth/redact"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"log/slog"
 	"net"
@@ -15,6 +20,28 @@ import (
 type Tokens struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
+	// TokenType тип токена из OAuth2 ответа (обычно "Bearer"), пуст для остальных форматов
+	TokenType string `json:"-"`
+	// ExpiresIn время жизни access токена из OAuth2 ответа (expires_in), 0 если сервер его не вернул
+	ExpiresIn time.Duration `json:"-"`
+	// ExpiresAt момент истечения access токена, рассчитанный по ExpiresIn при получении ответа.
+	// Нужен для непрозрачных (не JWT) access токенов.
+	ExpiresAt time.Time `json:"-"`
+}
+
+// LogValue реализует slog.LogValuer: токены логируются только отпечатком (redact.Fingerprint)
+func (t Tokens) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.Any("access_token", redact.Fingerprint(t.AccessToken)),
+		slog.Any("refresh_token", redact.Fingerprint(t.RefreshToken)),
+	}
+	if t.TokenType != "" {
+		attrs = append(attrs, slog.String("token_type", t.TokenType))
+	}
+	if !t.ExpiresAt.IsZero() {
+		attrs = append(attrs, slog.Time("expires_at", t.ExpiresAt))
+	}
+	return slog.GroupValue(attrs...)
 }
 
 type Credentials struct {
@@ -22,90 +49,256 @@ type Credentials struct {
 	Password string `json:"secretKey"`
 }
 
+// LogValue реализует slog.LogValuer: пароль или client_secret в логи не попадает
+func (c Credentials) LogValue() slog.Value {
+	password := ""
+	if c.Password != "" {
+		password = redact.Redacted
+	}
+	return slog.GroupValue(slog.String("username", c.Username), slog.String("password", password))
+}
+
 // LoginOrRefreshInService выполняет аутентификацию или обновление токена.
 // Поддерживает типы Credentials (для логина) и Tokens (для refresh).
 // Возвращает новые токены или ошибку.
 func LoginOrRefreshInService[T Credentials | Tokens](URL string, body T, log *slog.Logger, retryCount int) (*Tokens, error) {
+	return LoginOrRefreshInServiceContext(context.Background(), URL, body, log, retryCount)
+}
+
+// LoginOrRefreshInServiceContext аналог LoginOrRefreshInService, учитывающий контекст.
+// Отмена контекста прерывает текущий запрос и ожидание перед следующей попыткой.
+func LoginOrRefreshInServiceContext[T Credentials | Tokens](ctx context.Context, URL string, body T, log *slog.Logger, retryCount int) (*Tokens, error) {
+	client := NewClient(nil, WithRetryPolicy(ExponentialBackoff{MaxAttempts: retryCount + 1}))
+	if credentials, ok := any(body).(Credentials); ok {
+		return client.Login(ctx, URL, credentials, log)
+	}
+	return client.Refresh(ctx, URL, any(body).(Tokens), log)
+}
+
+// Login выполняет аутентификацию по логину и паролю
+func (c *Client) Login(ctx context.Context, URL string, credentials Credentials, log *slog.Logger) (*Tokens, error) {
+	return c.loginOrRefresh(ctx, "login", URL, log, []string{credentials.Password}, func(ctx context.Context) (*http.Request, error) {
+		return c.codec.NewLoginRequest(ctx, URL, credentials)
+	})
+}
+
+// Refresh обновляет пару токенов по refresh токену.
+// Если сервер не вернул новый refresh токен, в результате остаётся прежний.
+func (c *Client) Refresh(ctx context.Context, URL string, tokens Tokens, log *slog.Logger) (*Tokens, error) {
+	newTokens, err := c.loginOrRefresh(ctx, "refresh", URL, log, []string{tokens.AccessToken, tokens.RefreshToken}, func(ctx context.Context) (*http.Request, error) {
+		return c.codec.NewRefreshRequest(ctx, URL, tokens)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if newTokens.RefreshToken == "" {
+		newTokens.RefreshToken = tokens.RefreshToken
+	}
+	return newTokens, nil
+}
+
+// loginOrRefresh выполняет запрос, построенный newRequest, с повторами согласно политике клиента.
+// Общее время попыток ограничено RetryPolicy.Deadline, после его истечения возвращается последняя ошибка.
+// Значения secrets и секреты кодека скрываются в залогированном теле запроса, как бы ни назывались их поля.
+func (c *Client) loginOrRefresh(ctx context.Context, operation string, URL string, log *slog.Logger, secrets []string, newRequest func(ctx context.Context) (*http.Request, error)) (tokens *Tokens, err error) {
 	const op = "requests.LoginOrRefreshInService"
-	var operation string
-	switch any(body).(type) {
-	case Credentials:
-		operation = "login"
-	case Tokens:
-		operation = "refresh"
+
+	ctx, span := c.tracer.Start(ctx, "jwtauth."+operation, trace.WithAttributes(attribute.String("jwtauth.auth_type", operation)))
+	var attempts, lastStatus int
+	defer func() {
+		c.metrics.ObserveResult(operation, lastStatus, attempts, err)
+		endSpan(span, err, attribute.Int("jwtauth.attempts", attempts), attribute.Int("http.response.status_code", lastStatus))
+	}()
+
+	parent := ctx
+	if deadline := c.retry.Deadline(); deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, deadline)
+		defer cancel()
 	}
 
-	jsonData, err := json.Marshal(body)
+	req, err := newRequest(ctx)
 	if err != nil {
-		fmt.Printf("Ошибка при маршалинге JSON: %s\n", err)
 		return nil, err
 	}
 	log = log.With(
 		slog.String("operation", op),
 		slog.String("auth_type", operation),
 		slog.String("url", URL),
-		slog.String("body", string(jsonData)),
 	)
 
-	log.Debug("request body", slog.String("data", string(jsonData)))
-	for attempt := 0; attempt <= retryCount; attempt++ {
-		resp, err := makePostRequest(URL, jsonData, log)
-		if err != nil {
-			log.Error("Error in request: ", slog.String("error", err.Error()))
-			if attempt == retryCount {
+	if holder, ok := c.codec.(secretHolder); ok {
+		secrets = append(secrets, holder.secretValues()...)
+	}
+	log.Debug("request body", slog.String("data", redact.Body(req.Header.Get("Content-Type"), readRequestBody(req), secrets...)))
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			if req, err = newRequest(ctx); err != nil {
 				return nil, err
 			}
-			continue
 		}
-		defer resp.Body.Close()
 
-		//Выход из функции при положительном результате
-		if resp.StatusCode == http.StatusOK {
-			var tokens Tokens
-			err = json.NewDecoder(resp.Body).Decode(&tokens)
+		var lastErr error
+		attempts = attempt
+		resp, err := c.doAttempt(req, operation, attempt, log)
+		lastStatus = 0
+		if resp != nil {
+			lastStatus = resp.StatusCode
+		}
+		if err != nil {
+			log.Error("Error in request: ", slog.String("error", err.Error()))
+			if parent.Err() != nil {
+				return nil, err
+			}
+			lastErr = err
+		} else {
+			//Выход из функции при положительном результате
+			if resp.StatusCode == http.StatusOK {
+				defer resp.Body.Close()
+				tokens, err := c.codec.DecodeTokens(resp)
+				if err != nil {
+					return nil, err
+				}
+				if tokens.ExpiresIn > 0 {
+					tokens.ExpiresAt = c.clock.Now().Add(tokens.ExpiresIn)
+				}
+				return tokens, nil
+			}
+			//Читаем тело ошибки и логируем. Сервер может вернуть в нём отправленные секреты, поэтому они скрываются
+			respBody, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			body := redact.Body(resp.Header.Get("Content-Type"), string(respBody), secrets...)
+			log.Warn("server error",
+				"attempt", attempt,
+				"status", resp.StatusCode,
+				"body", body)
 			if err != nil {
-				return nil, fmt.Errorf("decode tokens: %w", err)
+				log.Error("Error while reading response body", slog.String("error", err.Error()))
+				return nil, err
+			}
+			httpErr := &HTTPError{
+				Operation:  operation,
+				StatusCode: resp.StatusCode,
+				Body:       body,
+				Attempts:   attempt,
 			}
+			// Разобранная ошибка сервера уточняет класс ошибки, но решение о повторе принимает политика по коду ответа
+			if decoder, ok := c.codec.(ErrorDecoder); ok {
+				httpErr.Err = decoder.DecodeError(resp, respBody)
+			}
+			lastErr = httpErr
+		}
 
-			return &tokens, nil
+		var delay time.Duration
+		var retry bool
+		if policy, ok := c.retry.(clockedRetryPolicy); ok {
+			delay, retry = policy.backoffAt(c.clock.Now(), attempt, resp, err)
+		} else {
+			delay, retry = c.retry.Backoff(attempt, resp, err)
 		}
-		//Читаем тело ошибки и логируем
-		respBody, err := io.ReadAll(resp.Body)
-		log.Warn("server error",
-			"attempt", attempt,
-			"status", resp.StatusCode,
-			"body", string(respBody))
-		if err != nil {
-			log.Error("Error while reading response body", slog.String("error", err.Error()))
-			return nil, err
+		if !retry {
+			return nil, lastErr
 		}
-		//Небольшая задержка перед следующей попыткой
-		time.Sleep(time.Duration(attempt) * time.Second)
+		log.Debug("retrying request", "attempt", attempt, "delay", delay)
+		//Задержка перед следующей попыткой
+		if err := sleepContext(ctx, c.clock, delay); err != nil {
+			if parent.Err() != nil {
+				return nil, parent.Err()
+			}
+			// Истёк общий лимит времени на попытки
+			return nil, lastErr
+		}
+	}
+}
 
+// doAttempt выполняет одну попытку запроса в отдельном span с контекстом трассировки в заголовках
+func (c *Client) doAttempt(req *http.Request, operation string, attempt int, log *slog.Logger) (resp *http.Response, err error) {
+	ctx, span := c.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("jwtauth.auth_type", operation),
+			attribute.Int("jwtauth.attempt", attempt),
+			attribute.String("http.request.method", req.Method),
+			attribute.String("server.address", req.URL.Hostname()),
+			attribute.String("url.path", req.URL.Path),
+		))
+	req = req.WithContext(ctx)
+	c.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
+	resp, err = makeRequest(c.httpClient, req, log)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status != http.StatusOK && err == nil {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
 	}
-	return nil, fmt.Errorf("after %d attempts login failed", retryCount)
+	c.metrics.ObserveAttempt(operation, status, time.Since(start))
+	endSpan(span, err)
+	return resp, err
+}
 
+// endSpan завершает span, отмечая ошибку. Текст ошибки не содержит тел запросов и токенов
+func endSpan(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
 }
 
-// makePostRequest Выполняет post запрос к api
+// makeRequest Выполняет запрос к api
 //
 // Возвращает ответ или ошибку
-func makePostRequest(URL string, data []byte, log *slog.Logger) (*http.Response, error) {
-	const op = "requests.makePostRequest"
-	resp, err := defaultClient.Post(URL, "application/json", bytes.NewBuffer(data))
-	// Обработка если произошла ошибка сети
+func makeRequest(client *http.Client, req *http.Request, log *slog.Logger) (*http.Response, error) {
+	const op = "requests.makeRequest"
 	log = log.With(
 		slog.String("operation", op),
-		slog.String("url", URL))
+		slog.String("url", req.URL.String()))
+	resp, err := client.Do(req)
+	// Обработка если произошла ошибка сети
 	if err != nil {
 		//Обработка ошибки по таймауту
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-			return nil, fmt.Errorf("request timeout: %w", err)
+			return nil, fmt.Errorf("%w: %w", ErrTimeout, err)
+		}
+		//Отмена запроса вызывающим не означает недоступность сервера
+		if req.Context().Err() != nil {
+			return nil, err
 		}
 		//Общая логика ошибок
 		log.Error("HTTP request failed: ", slog.String("error", err.Error()))
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrServerUnavailable, err)
 
 	}
 	return resp, nil
 }
+
+// readRequestBody возвращает копию тела запроса, не изменяя сам запрос
+func readRequestBody(req *http.Request) string {
+	if req.GetBody == nil {
+		return ""
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return ""
+	}
+	defer body.Close()
+	data, _ := io.ReadAll(body)
+	return string(data)
+}
+
+// sleepContext ждёт d или отмены контекста, в последнем случае возвращает ошибку контекста
+func sleepContext(ctx context.Context, clk clock.Clock, d time.Duration) error {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -2,17 +2,21 @@ package requests
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
 
-// TestMakePostRequest Тест основной функции выполнения запроса клиентом
-func TestMakePostRequest(t *testing.T) {
+// TestMakeRequest Тест основной функции выполнения запроса клиентом
+func TestMakeRequest(t *testing.T) {
 	// Создание тестового сервера
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		body, _ := io.ReadAll(r.Body)
@@ -37,17 +41,17 @@ func TestMakePostRequest(t *testing.T) {
 	defer testServer.Close()
 	//Сетапим тест кейсы
 	tests := []struct {
-		testName      string
-		requestBody   string
-		responseBody  string
-		responseCode  int
-		wantError     bool
-		errorContains string
+		testName     string
+		requestBody  string
+		responseBody string
+		responseCode int
+		wantError    bool
+		wantErr      error
 	}{
-		{"PositivePostRequest", `{"test": 1}`, "", http.StatusOK, false, ""},
-		{"NegativePostRequest", `{"test": 2}`, "", http.StatusBadRequest, false, ""},
-		{"ServerErrorTest", `{"test": 3}`, "", http.StatusInternalServerError, false, ""},
-		{"TimeoutTest", `{"test": 4}`, "", http.StatusRequestTimeout, true, "timeout"},
+		{"PositivePostRequest", `{"test": 1}`, "", http.StatusOK, false, nil},
+		{"NegativePostRequest", `{"test": 2}`, "", http.StatusBadRequest, false, nil},
+		{"ServerErrorTest", `{"test": 3}`, "", http.StatusInternalServerError, false, nil},
+		{"TimeoutTest", `{"test": 4}`, "", http.StatusRequestTimeout, true, ErrTimeout},
 	}
 	var logOutput bytes.Buffer
 	log := slog.New(slog.NewTextHandler(&logOutput, nil))
@@ -56,15 +60,19 @@ func TestMakePostRequest(t *testing.T) {
 			// Запуск подтеста
 			log.Info("launch test", slog.String("Test", tt.testName),
 				slog.String("request body from test case", tt.requestBody))
-			result, err := makePostRequest(testServer.URL, []byte(tt.requestBody), log)
+			req, err := http.NewRequest(http.MethodPost, testServer.URL, strings.NewReader(tt.requestBody))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := makeRequest(defaultClient, req, log)
 
 			if tt.wantError {
 				if err == nil {
 					t.Fatal("expected error")
 				}
 				log.Info("Got error", slog.String("error", err.Error()))
-				if !strings.Contains(err.Error(), tt.errorContains) {
-					t.Fatalf("expected error containing %q, got %q", tt.errorContains, logOutput.String())
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v error, got %q", tt.wantErr, err)
 				}
 				return
 			}
@@ -104,6 +112,13 @@ func TestLoginOrRefreshInService(t *testing.T) {
 		case `{"accessToken":"access3","refreshToken":"refresh3"}`:
 			time.Sleep(time.Second * 11)
 			return
+		case `{"accessKey":"test4","secretKey":"password4"}`:
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case `{"accessToken":"access4","refreshToken":"refresh4"}`:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
 
 		default:
 			w.WriteHeader(http.StatusTeapot)
@@ -111,62 +126,74 @@ func TestLoginOrRefreshInService(t *testing.T) {
 	}))
 	defer testServer.Close()
 	tests := []struct {
-		testName      string
-		requestBody   any
-		responseBody  string
-		responseCode  int
-		wantError     bool
-		errorContains string
-		retryCount    int
+		testName     string
+		requestBody  any
+		responseBody string
+		responseCode int
+		wantError    bool
+		wantErr      error
+		retryCount   int
 	}{
 		{"PositivePostRequestLogin",
 			Credentials{Username: "test", Password: "password"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusOK,
 			false,
-			"", 1},
+			nil, 1},
 		{"PositivePostRequestRefresh",
 			Tokens{AccessToken: "access", RefreshToken: "refresh"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusOK,
 			false,
-			"", 1},
+			nil, 1},
 		{"Negative400PostRequestLogin",
 			Credentials{Username: "test2", Password: "password2"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusBadRequest,
 			true,
-			"after 1 attempts login failed", 1},
+			ErrInvalidCredentials, 1},
 		{"Negative400PostRequestRefresh",
 			Tokens{AccessToken: "access2", RefreshToken: "refresh2"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusBadRequest,
 			true,
-			"after 1 attempts login failed", 1},
+			ErrRefreshTokenExpired, 1},
 		{"Negative3RetryCountPostRequestLogin",
 			Credentials{Username: "test2", Password: "password2"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusBadRequest,
 			true,
-			"after 3 attempts login failed", 3},
+			ErrInvalidCredentials, 3},
 		{"Negative3RetryCountPostRequestRefresh",
 			Tokens{AccessToken: "access2", RefreshToken: "refresh2"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusBadRequest,
 			true,
-			"after 3 attempts login failed", 3},
+			ErrRefreshTokenExpired, 3},
 		{"TimeoutPostRequestLogin",
 			Credentials{Username: "test3", Password: "password3"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusBadRequest,
 			true,
-			"timeout", 1},
+			ErrTimeout, 1},
 		{"TimeoutPostRequestRefresh",
 			Tokens{AccessToken: "access3", RefreshToken: "refresh3"},
 			`{"accessToken": "valid", "refreshToken": "valid"}`,
 			http.StatusBadRequest,
 			true,
-			"timeout", 1},
+			ErrTimeout, 1},
+		{"Negative500RetriedPostRequestLogin",
+			Credentials{Username: "test4", Password: "password4"},
+			`{"accessToken": "valid", "refreshToken": "valid"}`,
+			http.StatusInternalServerError,
+			true,
+			ErrServerUnavailable, 2},
+		{"Negative503RetriedPostRequestRefresh",
+			Tokens{AccessToken: "access4", RefreshToken: "refresh4"},
+			`{"accessToken": "valid", "refreshToken": "valid"}`,
+			http.StatusServiceUnavailable,
+			true,
+			ErrServerUnavailable, 3},
 	}
 	var logOutput bytes.Buffer
 	log := slog.New(slog.NewTextHandler(&logOutput, nil))
@@ -188,9 +215,23 @@ func TestLoginOrRefreshInService(t *testing.T) {
 				if err == nil {
 					t.Fatal("expected error, got nil")
 				}
-				if !strings.Contains(err.Error(), tt.errorContains) {
-					t.Fatalf("expected error containing %q, got %q", tt.errorContains, logOutput.String())
-
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v error, got %q", tt.wantErr, err)
+				}
+				// Ответ сервера с ошибкой возвращается вместе со статусом и числом попыток.
+				// 4xx не повторяются, 5xx повторяются retryCount раз
+				var httpErr *HTTPError
+				if errors.As(err, &httpErr) {
+					if httpErr.StatusCode != tt.responseCode {
+						t.Errorf("got %d status code, want %d", httpErr.StatusCode, tt.responseCode)
+					}
+					wantAttempts := 1
+					if IsRetryable(&http.Response{StatusCode: tt.responseCode}, nil) {
+						wantAttempts = tt.retryCount + 1
+					}
+					if httpErr.Attempts != wantAttempts {
+						t.Errorf("got %d attempts, want %d", httpErr.Attempts, wantAttempts)
+					}
 				}
 				return
 			}
@@ -205,3 +246,199 @@ func TestLoginOrRefreshInService(t *testing.T) {
 		})
 	}
 }
+
+// TestLoginOrRefreshInServiceContext Отмена контекста прерывает и запрос, и ожидание между попытками
+func TestLoginOrRefreshInServiceContext(t *testing.T) {
+	release := make(chan struct{})
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/hang" {
+			<-release
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer testServer.Close()
+	defer close(release)
+	tests := []struct {
+		testName string
+		path     string
+	}{
+		{"CancelHangingRequest", "/hang"},
+		{"CancelRetrySleep", "/error"},
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			started := time.Now()
+			_, err := LoginOrRefreshInServiceContext(ctx, testServer.URL+tt.path, Credentials{Username: "test", Password: "password"}, log, 5)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("expected context deadline error, got %v", err)
+			}
+			if elapsed := time.Since(started); elapsed > 2*time.Second {
+				t.Errorf("cancellation took %v", elapsed)
+			}
+		})
+	}
+}
+
+// TestClientRetryUsesClock Задержка между попытками отсчитывается по часам клиента
+func TestClientRetryUsesClock(t *testing.T) {
+	var calls atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"accessToken": "valid", "refreshToken": "valid"}`))
+	}))
+	defer testServer.Close()
+
+	fake := clocktesting.NewFakeClock(time.Now())
+	client := NewClient(nil,
+		WithRetryPolicy(ExponentialBackoff{MaxAttempts: 2, InitialInterval: time.Hour, Jitter: -1}),
+		WithClock(fake))
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Login(context.Background(), testServer.URL, Credentials{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		result <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fake.BlockUntil(ctx, 1); err != nil {
+		t.Fatalf("retry delay was not started: %v", err)
+	}
+	fake.Advance(time.Hour)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("retry did not happen after the clock advanced")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("got %d calls, want 2", got)
+	}
+}
+
+// TestClientRetryAfterDateUsesClock Retry-After в виде HTTP-даты отсчитывается по часам клиента
+func TestClientRetryAfterDateUsesClock(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	var calls atomic.Int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", fake.Now().Add(time.Hour).Format(http.TimeFormat))
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"accessToken": "valid", "refreshToken": "valid"}`))
+	}))
+	defer testServer.Close()
+
+	client := NewClient(nil, WithRetryPolicy(ExponentialBackoff{MaxAttempts: 2}), WithClock(fake))
+	result := make(chan error, 1)
+	go func() {
+		_, err := client.Login(context.Background(), testServer.URL, Credentials{}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		result <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := fake.BlockUntil(ctx, 1); err != nil {
+		t.Fatalf("retry delay was not started: %v", err)
+	}
+	fake.Advance(time.Hour)
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("Login failed: %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("retry did not happen after the clock reached Retry-After")
+	}
+}
+
+// TestSecretsAreNotLogged Пароль и токены не попадают в логи даже на уровне Debug
+func TestSecretsAreNotLogged(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/refresh" {
+			// Сервер возвращает отправленный refresh токен в теле ошибки
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "unavailable", "submitted": "secret-refresh-token"}`))
+			return
+		}
+		w.Write([]byte(`{"accessToken": "secret-access-token", "refreshToken": "secret-refresh-token"}`))
+	}))
+	defer testServer.Close()
+
+	var logOutput bytes.Buffer
+	log := slog.New(slog.NewTextHandler(&logOutput, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := NewClient(nil, WithRetryPolicy(ExponentialBackoff{MaxAttempts: 2, InitialInterval: time.Millisecond}))
+	credentials := Credentials{Username: "service", Password: "secret-password"}
+	tokens, err := client.Login(context.Background(), testServer.URL, credentials, log)
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	_, err = client.Refresh(context.Background(), testServer.URL+"/refresh", *tokens, log)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("got error %v, want *HTTPError", err)
+	}
+	if strings.Contains(httpErr.Body, "secret-refresh-token") || !strings.Contains(httpErr.Body, "unavailable") {
+		t.Errorf("got error body %s", httpErr.Body)
+	}
+	log.Info("tokens", "tokens", tokens, "credentials", credentials)
+
+	output := logOutput.String()
+	for _, secret := range []string{"secret-password", "secret-access-token", "secret-refresh-token"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("log output contains %q:\n%s", secret, output)
+		}
+	}
+	if !strings.Contains(output, "service") || !strings.Contains(output, "sha256=") {
+		t.Errorf("log output has no username or token fingerprint:\n%s", output)
+	}
+}
+
+func TestCodecSecretsAreNotLogged(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access": "access-token", "refresh": "refresh-token"}`))
+	}))
+	defer testServer.Close()
+
+	tests := []struct {
+		testName string
+		codec    JSONPathCodec
+	}{
+		{"PositiveNestedPassword", JSONPathCodec{UsernameField: "auth.login", PasswordField: "auth.password",
+			ExtraFields: map[string]string{"client.key": "secret-extra"}, AccessTokenPath: "access"}},
+		{"PositiveCustomNames", JSONPathCodec{UsernameField: "user", PasswordField: "pwd",
+			ExtraFields: map[string]string{"app_key": "secret-extra"}, AccessTokenPath: "access"}},
+		{"PositiveFormCustomNames", JSONPathCodec{UsernameField: "user", PasswordField: "pass",
+			ExtraFields: map[string]string{"app_key": "secret-extra"}, Form: true, AccessTokenPath: "access"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var logOutput bytes.Buffer
+			log := slog.New(slog.NewTextHandler(&logOutput, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			client := NewClient(nil, WithCodec(tt.codec))
+			if _, err := client.Login(context.Background(), testServer.URL, Credentials{Username: "service", Password: "secret-password"}, log); err != nil {
+				t.Fatalf("Login failed: %v", err)
+			}
+
+			output := logOutput.String()
+			for _, secret := range []string{"secret-password", "secret-extra"} {
+				if strings.Contains(output, secret) {
+					t.Errorf("log output contains %q:\n%s", secret, output)
+				}
+			}
+			if !strings.Contains(output, "service") {
+				t.Errorf("log output has no username:\n%s", output)
+			}
+		})
+	}
+}
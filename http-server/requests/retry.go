@@ -0,0 +1,174 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy решает, нужна ли повторная попытка запроса, и сколько ждать перед ней.
+type RetryPolicy interface {
+	// Backoff вызывается после каждой неудачной попытки.
+	// attempt - номер завершившейся попытки, начиная с 1.
+	// resp - ответ сервера (nil при сетевой ошибке), err - ошибка запроса.
+	// Возвращает задержку перед следующей попыткой и false, если повторять не нужно.
+	Backoff(attempt int, resp *http.Response, err error) (time.Duration, bool)
+	// Deadline ограничивает общее время всех попыток, 0 - без ограничения
+	Deadline() time.Duration
+}
+
+// LinearRetry повторяет запрос Count раз с линейно растущей задержкой:
+// 0, 1, 2... секунд после ответа сервера с ошибкой и без задержки после сетевой ошибки.
+// Повторяются только ошибки, для которых IsRetryable возвращает true.
+type LinearRetry struct {
+	Count int
+}
+
+func (r LinearRetry) Backoff(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt > r.Count || !IsRetryable(resp, err) {
+		return 0, false
+	}
+	if err != nil {
+		return 0, true
+	}
+	return time.Duration(attempt-1) * time.Second, true
+}
+
+func (LinearRetry) Deadline() time.Duration {
+	return 0
+}
+
+// Значения ExponentialBackoff по умолчанию
+const (
+	DefaultMaxAttempts     = 4
+	DefaultInitialInterval = 500 * time.Millisecond
+	DefaultMaxInterval     = 30 * time.Second
+	DefaultMultiplier      = 2
+	DefaultJitter          = 0.5
+	DefaultMaxElapsedTime  = 2 * time.Minute
+)
+
+// ExponentialBackoff - политика повторов по умолчанию: задержка растёт экспоненциально
+// от InitialInterval до MaxInterval и случайно отклоняется на ±Jitter,
+// чтобы реплики не повторяли запросы одновременно.
+// Повторяются только ошибки, для которых IsRetryable возвращает true, заголовок Retry-After учитывается.
+// Нулевые поля заменяются значениями по умолчанию.
+type ExponentialBackoff struct {
+	// MaxAttempts общее число попыток, включая первую
+	MaxAttempts int
+	// InitialInterval задержка после первой неудачной попытки
+	InitialInterval time.Duration
+	// MaxInterval максимальная задержка между попытками (без учёта Retry-After)
+	MaxInterval time.Duration
+	// Multiplier во сколько раз растёт задержка с каждой попыткой
+	Multiplier float64
+	// Jitter доля случайного отклонения задержки, от 0 до 1. Отрицательное значение отключает разброс
+	Jitter float64
+	// MaxElapsedTime общее время на все попытки
+	MaxElapsedTime time.Duration
+}
+
+func (b ExponentialBackoff) Backoff(attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	return b.backoffAt(time.Now(), attempt, resp, err)
+}
+
+// backoffAt аналог Backoff, отсчитывающий Retry-After в виде HTTP-даты от now.
+// Client передаёт сюда время своих часов (WithClock)
+func (b ExponentialBackoff) backoffAt(now time.Time, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	maxAttempts := b.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if attempt >= maxAttempts || !IsRetryable(resp, err) {
+		return 0, false
+	}
+	if retryAfter, ok := RetryAfterAt(resp, now); ok {
+		return retryAfter, true
+	}
+
+	initial := orDefault(b.InitialInterval, DefaultInitialInterval)
+	maxInterval := orDefault(b.MaxInterval, DefaultMaxInterval)
+	multiplier := b.Multiplier
+	if multiplier == 0 {
+		multiplier = DefaultMultiplier
+	}
+	jitter := b.Jitter
+	if jitter == 0 {
+		jitter = DefaultJitter
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if jitter > 0 {
+		delay *= 1 - jitter + 2*jitter*rand.Float64()
+	}
+	return min(time.Duration(delay), maxInterval), true
+}
+
+func (b ExponentialBackoff) Deadline() time.Duration {
+	return orDefault(b.MaxElapsedTime, DefaultMaxElapsedTime)
+}
+
+// IsRetryable сообщает, имеет ли смысл повторять запрос после такого ответа или ошибки.
+// Повторяются сетевые ошибки, таймауты и статусы 408, 425, 429, 500, 502, 503, 504.
+// Остальные ответы (400, 401, 403 и т.д.) при повторе не изменятся,
+// отмена контекста вызывающим тоже не повторяется.
+func IsRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled) &&
+			(!errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrTimeout))
+	}
+	if resp == nil {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// clockedRetryPolicy - политика, которой Client передаёт текущее время своих часов
+type clockedRetryPolicy interface {
+	backoffAt(now time.Time, attempt int, resp *http.Response, err error) (time.Duration, bool)
+}
+
+// RetryAfter разбирает заголовок Retry-After (число секунд или HTTP-дата).
+// HTTP-дата отсчитывается от системного времени
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	return RetryAfterAt(resp, time.Now())
+}
+
+// RetryAfterAt аналог RetryAfter, отсчитывающий HTTP-дату от now
+func RetryAfterAt(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+	return d
+}
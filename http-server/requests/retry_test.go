@@ -0,0 +1,97 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	withStatus := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set("Retry-After", retryAfter)
+		}
+		return resp
+	}
+	policy := ExponentialBackoff{
+		MaxAttempts:     4,
+		InitialInterval: 100 * time.Millisecond,
+		MaxInterval:     300 * time.Millisecond,
+		Jitter:          -1,
+	}
+	tests := []struct {
+		testName  string
+		attempt   int
+		resp      *http.Response
+		err       error
+		wantDelay time.Duration
+		wantRetry bool
+	}{
+		{"FirstRetry", 1, withStatus(http.StatusServiceUnavailable, ""), nil, 100 * time.Millisecond, true},
+		{"ExponentialGrowth", 2, withStatus(http.StatusBadGateway, ""), nil, 200 * time.Millisecond, true},
+		{"CappedByMaxInterval", 3, withStatus(http.StatusInternalServerError, ""), nil, 300 * time.Millisecond, true},
+		{"AttemptsExhausted", 4, withStatus(http.StatusInternalServerError, ""), nil, 0, false},
+		{"RetryAfterSeconds", 1, withStatus(http.StatusTooManyRequests, "2"), nil, 2 * time.Second, true},
+		{"NetworkError", 1, nil, fmt.Errorf("%w: connection refused", ErrServerUnavailable), 100 * time.Millisecond, true},
+		{"TimeoutError", 1, nil, fmt.Errorf("%w: %w", ErrTimeout, context.DeadlineExceeded), 100 * time.Millisecond, true},
+		{"NegativeBadRequest", 1, withStatus(http.StatusBadRequest, ""), nil, 0, false},
+		{"NegativeUnauthorized", 1, withStatus(http.StatusUnauthorized, ""), nil, 0, false},
+		{"NegativeCanceled", 1, nil, context.Canceled, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			delay, retry := policy.Backoff(tt.attempt, tt.resp, tt.err)
+			if retry != tt.wantRetry {
+				t.Fatalf("got retry %v, want %v", retry, tt.wantRetry)
+			}
+			if delay != tt.wantDelay {
+				t.Errorf("got delay %v, want %v", delay, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestExponentialBackoffJitter(t *testing.T) {
+	policy := ExponentialBackoff{InitialInterval: time.Second, Jitter: 0.5}
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable}
+	for i := 0; i < 100; i++ {
+		delay, _ := policy.Backoff(1, resp, nil)
+		if delay < 500*time.Millisecond || delay > 1500*time.Millisecond {
+			t.Fatalf("delay %v is out of jitter bounds", delay)
+		}
+	}
+}
+
+// TestRetryDeadline Общий лимит времени прерывает повторы и возвращает последнюю ошибку сервера
+func TestRetryDeadline(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer testServer.Close()
+
+	client := NewClient(nil, WithRetryPolicy(ExponentialBackoff{
+		MaxAttempts:     100,
+		InitialInterval: 50 * time.Millisecond,
+		MaxInterval:     50 * time.Millisecond,
+		MaxElapsedTime:  300 * time.Millisecond,
+	}))
+	started := time.Now()
+	_, err := client.Login(context.Background(), testServer.URL, Credentials{Username: "user"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("retries took %v", elapsed)
+	}
+	if !errors.Is(err, ErrServerUnavailable) {
+		t.Errorf("expected ErrServerUnavailable, got %v", err)
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.Attempts < 2 {
+		t.Errorf("expected several attempts, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+// Package metrics содержит Prometheus коллекторы для JWTAuth: число логинов и обновлений по результату
+// и коду ответа, длительность запросов к сервису авторизации, число повторов, срок действия текущего токена
+// и время с последнего успешного обновления.
+package metrics
+
+import (
+	"github.com/ShlykovPavel/JWTAuth/clock"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Namespace - префикс имён метрик
+const Namespace = "jwtauth"
+
+// Collector реализует auth.Metrics. Метрики помечены меткой identity: у клиента, подключённого
+// через сам Collector, она пуста, а для учётных записей Manager задаётся через Identity.
+type Collector struct {
+	*collectors
+	identity string
+}
+
+// collectors - метрики, общие для всех учётных записей
+type collectors struct {
+	clock    clock.Clock
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	retries  *prometheus.CounterVec
+	expiry   *prometheus.GaugeVec
+	renewal  *sinceRenewal
+}
+
+// Option настраивает Collector
+type Option func(*options)
+
+type options struct {
+	buckets []float64
+	clock   clock.Clock
+}
+
+// WithBuckets задаёт границы гистограммы длительности запросов в секундах, по умолчанию prometheus.DefBuckets
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// WithClock задаёт источник текущего времени для времени с последнего обновления, по умолчанию clock.System
+func WithClock(c clock.Clock) Option {
+	return func(o *options) {
+		o.clock = c
+	}
+}
+
+// New создаёт коллекторы и регистрирует их в reg
+func New(reg prometheus.Registerer, opts ...Option) (*Collector, error) {
+	o := options{buckets: prometheus.DefBuckets, clock: clock.System}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	c := &collectors{
+		clock: o.clock,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "requests_total",
+			Help:      "Login and refresh operations by outcome and last response status (0 if no response was received).",
+		}, []string{"identity", "operation", "outcome", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: Namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests to the identity provider, one observation per attempt.",
+			Buckets:   o.buckets,
+		}, []string{"identity", "operation", "status"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: Namespace,
+			Name:      "retries_total",
+			Help:      "Repeated attempts of login and refresh requests.",
+		}, []string{"identity", "operation"}),
+		expiry: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: Namespace,
+			Name:      "token_expiry_timestamp_seconds",
+			Help:      "Expiry of the current access token as a Unix timestamp.",
+		}, []string{"identity"}),
+		renewal: &sinceRenewal{
+			clock: o.clock,
+			desc: prometheus.NewDesc(prometheus.BuildFQName(Namespace, "", "seconds_since_last_renewal"),
+				"Seconds since tokens were last obtained from the identity provider.", []string{"identity"}, nil),
+			last: make(map[string]time.Time),
+		},
+	}
+	for _, collector := range []prometheus.Collector{c.requests, c.duration, c.retries, c.expiry, c.renewal} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return &Collector{collectors: c}, nil
+}
+
+// Identity возвращает Collector, помечающий метрики учётной записью name:
+//
+//	manager.Add("billing", auth.WithMetrics(collector.Identity("billing")))
+func (c *Collector) Identity(name string) *Collector {
+	return &Collector{collectors: c.collectors, identity: name}
+}
+
+func (c *Collector) ObserveAttempt(operation string, status int, duration time.Duration) {
+	c.duration.WithLabelValues(c.identity, operation, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+func (c *Collector) ObserveResult(operation string, status int, attempts int, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	c.requests.WithLabelValues(c.identity, operation, outcome, strconv.Itoa(status)).Inc()
+	if attempts > 1 {
+		c.retries.WithLabelValues(c.identity, operation).Add(float64(attempts - 1))
+	}
+}
+
+func (c *Collector) ObserveTokens(expiresAt time.Time, renewed bool) {
+	if expiresAt.IsZero() {
+		c.expiry.DeleteLabelValues(c.identity)
+	} else {
+		c.expiry.WithLabelValues(c.identity).Set(float64(expiresAt.Unix()))
+	}
+	if renewed {
+		c.renewal.set(c.identity, c.clock.Now())
+	}
+}
+
+// sinceRenewal считает время с последнего получения токенов в момент сбора метрик
+type sinceRenewal struct {
+	clock clock.Clock
+	desc  *prometheus.Desc
+
+	mu   sync.Mutex
+	last map[string]time.Time
+}
+
+func (s *sinceRenewal) set(identity string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last[identity] = at
+}
+
+func (s *sinceRenewal) Describe(ch chan<- *prometheus.Desc) {
+	ch <- s.desc
+}
+
+func (s *sinceRenewal) Collect(ch chan<- prometheus.Metric) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	for identity, at := range s.last {
+		ch <- prometheus.MustNewConstMetric(s.desc, prometheus.GaugeValue, now.Sub(at).Seconds(), identity)
+	}
+}
@@ -0,0 +1,77 @@
+package metrics_test
+
+import (
+	"github.com/ShlykovPavel/JWTAuth/auth"
+	"github.com/ShlykovPavel/JWTAuth/authtest"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/ShlykovPavel/JWTAuth/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollector(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	provider := authtest.NewProvider(authtest.WithClock(fake))
+	defer provider.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	collector, err := metrics.New(reg, metrics.WithClock(fake))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	jwtauth, err := auth.New(
+		auth.WithEndpoints(provider.LoginURL(), provider.RefreshURL()),
+		auth.WithRetryPolicy(requests.ExponentialBackoff{MaxAttempts: 2, InitialInterval: time.Millisecond}),
+		auth.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		auth.WithMetrics(collector.Identity("billing")),
+	)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	defer jwtauth.Stop()
+
+	provider.FailNext(authtest.Login, authtest.ServerError)
+	if err := jwtauth.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	provider.FailNext(authtest.Refresh, authtest.Unauthorized)
+	if err := jwtauth.Refresh(); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	fake.Advance(90 * time.Second)
+
+	expected := `
+# HELP jwtauth_requests_total Login and refresh operations by outcome and last response status (0 if no response was received).
+# TYPE jwtauth_requests_total counter
+jwtauth_requests_total{identity="billing",operation="login",outcome="success",status="200"} 2
+jwtauth_requests_total{identity="billing",operation="refresh",outcome="failure",status="401"} 1
+# HELP jwtauth_retries_total Repeated attempts of login and refresh requests.
+# TYPE jwtauth_retries_total counter
+jwtauth_retries_total{identity="billing",operation="login"} 1
+# HELP jwtauth_seconds_since_last_renewal Seconds since tokens were last obtained from the identity provider.
+# TYPE jwtauth_seconds_since_last_renewal gauge
+jwtauth_seconds_since_last_renewal{identity="billing"} 90
+# HELP jwtauth_token_expiry_timestamp_seconds Expiry of the current access token as a Unix timestamp.
+# TYPE jwtauth_token_expiry_timestamp_seconds gauge
+jwtauth_token_expiry_timestamp_seconds{identity="billing"} 1.8934596e+09
+`
+	err = testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"jwtauth_requests_total", "jwtauth_retries_total", "jwtauth_seconds_since_last_renewal", "jwtauth_token_expiry_timestamp_seconds")
+	if err != nil {
+		t.Error(err)
+	}
+	// Каждая попытка попадает в гистограмму: два логина при старте, отклонённый refresh и повторный логин
+	if got := testutil.CollectAndCount(reg, "jwtauth_request_duration_seconds"); got != 3 {
+		t.Errorf("got %d duration series, want 3", got)
+	}
+
+	if _, err := metrics.New(reg); err == nil {
+		t.Error("expected error on duplicate registration")
+	}
+}
@@ -0,0 +1,81 @@
+package redact
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler - обёртка над slog.Handler, скрывающая секреты в атрибутах записей, в том числе во вложенных группах
+// и в атрибутах, добавленных через Logger.With. Значения полей с известными именами секретов (password, secretKey,
+// client_secret, Authorization и т.д.) заменяются на Redacted, а токенов (token, accessToken, refresh_token) - на Fingerprint.
+type Handler struct {
+	next slog.Handler
+	keys map[string]bool
+}
+
+// HandlerOption настраивает Handler
+type HandlerOption func(*Handler)
+
+// WithKeys добавляет имена полей, значения которых заменяются на Redacted
+func WithKeys(keys ...string) HandlerOption {
+	return func(h *Handler) {
+		for _, key := range keys {
+			h.keys[normalizeKey(key)] = true
+		}
+	}
+}
+
+// NewHandler оборачивает next:
+//
+//	logger := slog.New(redact.NewHandler(slog.NewJSONHandler(os.Stdout, nil)))
+func NewHandler(next slog.Handler, opts ...HandlerOption) *Handler {
+	h := &Handler{next: next, keys: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redact(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = h.redact(attr)
+	}
+	return &Handler{next: h.next.WithAttrs(redacted), keys: h.keys}
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	return &Handler{next: h.next.WithGroup(name), keys: h.keys}
+}
+
+// redact заменяет значение секрета. LogValuer разрешается заранее, чтобы проверить и его поля
+func (h *Handler) redact(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	switch {
+	case attr.Value.Kind() == slog.KindGroup:
+		group := attr.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, member := range group {
+			redacted[i] = h.redact(member)
+		}
+		attr.Value = slog.GroupValue(redacted...)
+	case isTokenKey(attr.Key) && attr.Value.Kind() == slog.KindString:
+		attr.Value = Fingerprint(attr.Value.String())
+	case IsSecretKey(attr.Key) || h.keys[normalizeKey(attr.Key)]:
+		attr.Value = slog.StringValue(Redacted)
+	}
+	return attr
+}
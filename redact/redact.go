@@ -0,0 +1,159 @@
+// Package redact скрывает секреты в логах: учётные данные заменяются на Redacted,
+// а токены - на короткий отпечаток (префикс SHA-256, kid, sub, exp), по которому их можно сопоставить,
+// но нельзя использовать. Handler применяет те же правила к атрибутам любых записей slog.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"net/url"
+	"strings"
+)
+
+// Redacted заменяет значение секрета в логах
+const Redacted = "[REDACTED]"
+
+// fingerprintLength - число шестнадцатеричных символов SHA-256 в отпечатке токена
+const fingerprintLength = 12
+
+// secretKeys - известные имена полей с секретами, приведённые normalizeKey
+var secretKeys = map[string]bool{
+	"password":        true,
+	"secret":          true,
+	"secretkey":       true,
+	"clientsecret":    true,
+	"clientassertion": true,
+	"assertion":       true,
+	"apikey":          true,
+	"authorization":   true,
+	"cookie":          true,
+	"setcookie":       true,
+}
+
+// tokenKeys - имена полей с токенами, их значения заменяются отпечатком
+var tokenKeys = map[string]bool{
+	"token":        true,
+	"accesstoken":  true,
+	"refreshtoken": true,
+	"idtoken":      true,
+}
+
+// normalizeKey приводит имя поля к виду без регистра и разделителей: refresh_token, refreshToken и Refresh-Token совпадают
+func normalizeKey(key string) string {
+	return strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+}
+
+// IsSecretKey сообщает, что поле с таким именем содержит секрет или токен и не должно попадать в логи как есть
+func IsSecretKey(key string) bool {
+	key = normalizeKey(key)
+	return secretKeys[key] || tokenKeys[key]
+}
+
+// isTokenKey сообщает, что значение поля - токен, который логируется отпечатком
+func isTokenKey(key string) bool {
+	return tokenKeys[normalizeKey(key)]
+}
+
+// Fingerprint возвращает отпечаток токена для логов: первые символы SHA-256, а для JWT ещё kid, sub и exp.
+// Подпись и остальные claims не раскрываются. Для пустого токена возвращается пустая строка.
+func Fingerprint(token string) slog.Value {
+	if token == "" {
+		return slog.StringValue("")
+	}
+	sum := sha256.Sum256([]byte(token))
+	attrs := []slog.Attr{slog.String("sha256", hex.EncodeToString(sum[:])[:fingerprintLength])}
+
+	claims := jwt.MapClaims{}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	if err != nil {
+		return slog.GroupValue(attrs...)
+	}
+	if kid, ok := parsed.Header["kid"].(string); ok && kid != "" {
+		attrs = append(attrs, slog.String("kid", kid))
+	}
+	if sub, err := claims.GetSubject(); err == nil && sub != "" {
+		attrs = append(attrs, slog.String("sub", sub))
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		attrs = append(attrs, slog.Time("exp", exp.Time))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// Body возвращает тело запроса к сервису авторизации со скрытыми секретами.
+// Скрываются значения полей с известными именами секретов на любом уровне вложенности и любые значения,
+// совпадающие с secrets (например паролем или refresh токеном, которые вызывающий передал в запрос),
+// поэтому нестандартные имена полей вроде "pwd" или "auth.password" тоже не раскрывают секрет.
+// Поддерживаются JSON и формы (application/x-www-form-urlencoded); тело другого формата, а также тело,
+// в котором секрет остался внутри другой строки, целиком заменяется на Redacted.
+func Body(contentType, body string, secrets ...string) string {
+	if body == "" {
+		return ""
+	}
+	known := make(map[string]bool, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" {
+			known[secret] = true
+		}
+	}
+
+	var redacted string
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return Redacted
+		}
+		for key, list := range values {
+			for i, value := range list {
+				if IsSecretKey(key) || known[value] {
+					list[i] = Redacted
+				}
+			}
+		}
+		redacted = values.Encode()
+	} else {
+		var fields any
+		if err := json.Unmarshal([]byte(body), &fields); err != nil {
+			return Redacted
+		}
+		data, err := json.Marshal(redactJSON(fields, known))
+		if err != nil {
+			return Redacted
+		}
+		redacted = string(data)
+	}
+
+	// Секрет мог оказаться частью другого значения, например "Bearer <token>"
+	for secret := range known {
+		if strings.Contains(redacted, secret) || strings.Contains(redacted, url.QueryEscape(secret)) {
+			return Redacted
+		}
+	}
+	return redacted
+}
+
+// redactJSON скрывает секреты в разобранном JSON значении, обходя вложенные объекты и массивы
+func redactJSON(value any, secrets map[string]bool) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, field := range v {
+			if IsSecretKey(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = redactJSON(field, secrets)
+			}
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = redactJSON(item, secrets)
+		}
+	case string:
+		if secrets[v] {
+			return Redacted
+		}
+	}
+	return value
+}
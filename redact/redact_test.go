@@ -0,0 +1,133 @@
+package redact_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"github.com/ShlykovPavel/JWTAuth/redact"
+	"github.com/golang-jwt/jwt/v5"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedToken(t *testing.T) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   "service",
+		"exp":   float64(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Unix()),
+		"email": "service@example.com",
+	})
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString([]byte("key"))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestFingerprint(t *testing.T) {
+	token := signedToken(t)
+	tests := []struct {
+		testName string
+		token    string
+		want     []string
+		wantNot  []string
+	}{
+		{"PositiveJWT", token, []string{"sha256=", "kid=key-1", "sub=service", "exp=2030-01-01T00:00:00.000Z"}, []string{token, "example.com"}},
+		{"PositiveOpaque", "opaque-token", []string{"sha256="}, []string{"opaque-token", "sub="}},
+		{"PositiveEmpty", "", []string{"token=\"\""}, []string{"sha256="}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			var out bytes.Buffer
+			slog.New(slog.NewTextHandler(&out, nil)).Info("test", slog.Any("token", redact.Fingerprint(tt.token)))
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("got %q, want it to contain %q", out.String(), want)
+				}
+			}
+			for _, wantNot := range tt.wantNot {
+				if strings.Contains(out.String(), wantNot) {
+					t.Errorf("got %q, must not contain %q", out.String(), wantNot)
+				}
+			}
+		})
+	}
+	if a, b := redact.Fingerprint("one").String(), redact.Fingerprint("two").String(); a == b {
+		t.Error("different tokens have the same fingerprint")
+	}
+}
+
+func TestBody(t *testing.T) {
+	tests := []struct {
+		testName    string
+		contentType string
+		body        string
+		secrets     []string
+		want        string
+	}{
+		{"PositiveJSON", "application/json", `{"accessKey":"user","secretKey":"pass"}`, nil, `{"accessKey":"user","secretKey":"[REDACTED]"}`},
+		{"PositiveJSONRefresh", "application/json", `{"accessToken":"a","refreshToken":"r"}`, nil, `{"accessToken":"[REDACTED]","refreshToken":"[REDACTED]"}`},
+		{"PositiveJSONNested", "application/json", `{"auth":{"login":"user","password":"hunter2"},"scopes":["read"]}`, nil,
+			`{"auth":{"login":"user","password":"[REDACTED]"},"scopes":["read"]}`},
+		{"PositiveJSONCustomName", "application/json", `{"user":{"name":"user","pwd":"hunter2"}}`, []string{"hunter2"},
+			`{"user":{"name":"user","pwd":"[REDACTED]"}}`},
+		{"PositiveForm", "application/x-www-form-urlencoded", "client_id=app&client_secret=s&grant_type=client_credentials", nil,
+			"client_id=app&client_secret=%5BREDACTED%5D&grant_type=client_credentials"},
+		{"PositiveFormCustomName", "application/x-www-form-urlencoded", "app_key=k%26ey&pass=hunter2&user=user", []string{"hunter2", "k&ey"},
+			"app_key=%5BREDACTED%5D&pass=%5BREDACTED%5D&user=user"},
+		{"PositiveEmpty", "application/json", "", nil, ""},
+		{"NegativeSecretInsideValue", "application/json", `{"header":"Bearer hunter2"}`, []string{"hunter2"}, redact.Redacted},
+		{"NegativeNotJSON", "text/plain", "password", nil, redact.Redacted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			if got := redact.Body(tt.contentType, tt.body, tt.secrets...); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var out bytes.Buffer
+	log := slog.New(redact.NewHandler(slog.NewJSONHandler(&out, nil), redact.WithKeys("pin")))
+	token := signedToken(t)
+
+	log.With("password", "with-secret").WithGroup("request").Info("login",
+		slog.String("Authorization", "Bearer "+token),
+		slog.String("refresh_token", token),
+		slog.Group("form", slog.String("client_secret", "form-secret"), slog.String("client_id", "app")),
+		slog.Any("credentials", requests.Credentials{Username: "user", Password: "valuer-secret"}),
+		slog.Int("pin", 1234),
+		slog.String("user", "user"),
+	)
+
+	output := out.String()
+	for _, secret := range []string{"with-secret", token, "form-secret", "valuer-secret", "1234"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("log output contains %q: %s", secret, output)
+		}
+	}
+	var record struct {
+		Password string `json:"password"`
+		Request  struct {
+			RefreshToken struct {
+				Sub string `json:"sub"`
+			} `json:"refresh_token"`
+			Form struct {
+				ClientID string `json:"client_id"`
+			} `json:"form"`
+			User string `json:"user"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("failed to decode log record: %v", err)
+	}
+	if record.Password != redact.Redacted || record.Request.RefreshToken.Sub != "service" ||
+		record.Request.Form.ClientID != "app" || record.Request.User != "user" {
+		t.Errorf("got record %+v", record)
+	}
+}
@@ -0,0 +1,226 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Queue - общий планировщик обновлений для многих токенов.
+// Вместо горутины и таймера на каждый токен работает одна горутина с одним таймером,
+// а ближайшее обновление берётся из очереди с приоритетом по времени.
+type Queue struct {
+	settings
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	logger *slog.Logger
+	items  queueHeap
+	index  map[string]*queueItem
+	// wake будит горутину, когда изменилось ближайшее обновление
+	wake chan struct{}
+}
+
+type queueItem struct {
+	key       string
+	due       time.Time
+	onRefresh func(ctx context.Context)
+	// ctx отменяется, когда запись перепланируют или удаляют, в том числе во время onRefresh
+	ctx    context.Context
+	cancel context.CancelFunc
+	// heapIndex позиция в очереди, -1 после срабатывания
+	heapIndex int
+}
+
+// NewQueue создаёт очередь и запускает её горутину. Очередь работает до отмены ctx или вызова Stop.
+// WithRefreshSkew, WithRefreshStrategy и WithRefreshInterval задают значения по умолчанию для записей очереди.
+func NewQueue(ctx context.Context, logger *slog.Logger, opts ...Option) *Queue {
+	q := &Queue{
+		settings: defaultSettings(),
+		logger:   logger,
+		index:    make(map[string]*queueItem),
+		wake:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(&q.settings)
+	}
+	q.ctx, q.cancel = context.WithCancel(ctx)
+	go q.run()
+	return q
+}
+
+// Stop останавливает очередь и отменяет все запланированные и выполняющиеся обновления
+func (q *Queue) Stop() {
+	q.cancel()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for key := range q.index {
+		q.removeUnlocked(key)
+	}
+}
+
+// Len возвращает число записей, ожидающих обновления
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Entry возвращает запись очереди с ключом key. Запись реализует те же ScheduleRefresh и Stop,
+// что и Scheduler, поэтому может заменить собственный планировщик токена.
+// opts переопределяют параметры очереди для этой записи.
+func (q *Queue) Entry(key string, onRefresh func(ctx context.Context), opts ...Option) *Entry {
+	return q.EntryContext(context.Background(), key, onRefresh, opts...)
+}
+
+// EntryContext аналог Entry, привязанный к контексту владельца записи.
+// После отмены ctx запись удаляется из очереди и новые обновления для неё не планируются,
+// даже если ScheduleRefresh вызван позже, а onRefresh уже выполняющегося обновления получает отменённый контекст.
+func (q *Queue) EntryContext(ctx context.Context, key string, onRefresh func(ctx context.Context), opts ...Option) *Entry {
+	e := &Entry{settings: q.settings, ctx: ctx, queue: q, key: key, onRefresh: onRefresh}
+	for _, opt := range opts {
+		opt(&e.settings)
+	}
+	context.AfterFunc(ctx, e.Stop)
+	return e
+}
+
+// Entry - запись в Queue
+type Entry struct {
+	settings
+	ctx       context.Context
+	queue     *Queue
+	key       string
+	onRefresh func(ctx context.Context)
+}
+
+// ScheduleRefresh планирует обновление токена, истекающего в expiry, заменяя ранее запланированное для этой записи
+func (e *Entry) ScheduleRefresh(expiry time.Time) {
+	e.ScheduleRefreshLifetime(Lifetime{ExpiresAt: expiry})
+}
+
+// ScheduleRefreshLifetime планирует обновление токена по стратегии записи, заменяя ранее запланированное.
+// Возвращает момент запланированного обновления или нулевое время, если очередь остановлена или контекст записи отменён.
+func (e *Entry) ScheduleRefreshLifetime(lifetime Lifetime) time.Time {
+	refreshIn := e.refreshIn(lifetime)
+	e.queue.logger.Debug("refresh scheduled", slog.String("op", "scheduler.Entry.ScheduleRefresh"),
+		slog.String("key", e.key), slog.Any("time to refresh", refreshIn))
+	due := e.queue.clock.Now().Add(refreshIn)
+	if !e.queue.schedule(e.ctx, e.key, due, e.onRefresh) {
+		return time.Time{}
+	}
+	return due
+}
+
+// Stop удаляет запись из очереди
+func (e *Entry) Stop() {
+	e.queue.remove(e.key)
+}
+
+// schedule добавляет обновление записи key. Контекст записи проверяется под q.mu,
+// поэтому запись не может вернуться в очередь после того, как её удалил AfterFunc отменённого контекста
+func (q *Queue) schedule(entryCtx context.Context, key string, due time.Time, onRefresh func(ctx context.Context)) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeUnlocked(key)
+
+	if q.ctx.Err() != nil {
+		q.logger.Debug("queue is stopped, refresh is not scheduled", slog.String("key", key))
+		return false
+	}
+	if entryCtx.Err() != nil {
+		q.logger.Debug("entry is stopped, refresh is not scheduled", slog.String("key", key))
+		return false
+	}
+	ctx, cancel := context.WithCancel(q.ctx)
+	item := &queueItem{key: key, due: due, onRefresh: onRefresh, ctx: ctx, cancel: cancel}
+	heap.Push(&q.items, item)
+	q.index[key] = item
+	q.notify()
+	return true
+}
+
+func (q *Queue) remove(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeUnlocked(key)
+}
+
+func (q *Queue) removeUnlocked(key string) {
+	item, ok := q.index[key]
+	if !ok {
+		return
+	}
+	item.cancel()
+	if item.heapIndex >= 0 {
+		heap.Remove(&q.items, item.heapIndex)
+		q.notify()
+	}
+	delete(q.index, key)
+}
+
+// notify будит горутину очереди, не блокируясь, если она уже разбужена
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *Queue) run() {
+	timer := q.clock.NewTimer(0)
+	timer.Stop()
+	for {
+		q.mu.Lock()
+		now := q.clock.Now()
+		for len(q.items) > 0 && !q.items[0].due.After(now) {
+			item := heap.Pop(&q.items).(*queueItem)
+			go item.onRefresh(item.ctx)
+		}
+		var timerC <-chan time.Time
+		if len(q.items) > 0 {
+			timer.Reset(q.items[0].due.Sub(now))
+			timerC = timer.C()
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-timerC:
+		case <-q.wake:
+			timer.Stop()
+		case <-q.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// queueHeap - очередь с приоритетом по времени обновления (container/heap)
+type queueHeap []*queueItem
+
+func (h queueHeap) Len() int           { return len(h) }
+func (h queueHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+
+func (h queueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIndex = i
+	h[j].heapIndex = j
+}
+
+func (h *queueHeap) Push(x any) {
+	item := x.(*queueItem)
+	item.heapIndex = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *queueHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	item.heapIndex = -1
+	*h = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,95 @@
+package scheduler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueue(t *testing.T) {
+	queue := NewQueue(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer queue.Stop()
+
+	var (
+		mu    sync.Mutex
+		fired []string
+		done  = make(chan struct{}, 4)
+	)
+	record := func(key string) func(context.Context) {
+		return func(context.Context) {
+			mu.Lock()
+			fired = append(fired, key)
+			mu.Unlock()
+			done <- struct{}{}
+		}
+	}
+
+	now := time.Now()
+	queue.schedule(context.Background(), "c", now.Add(90*time.Millisecond), record("c"))
+	queue.schedule(context.Background(), "a", now.Add(30*time.Millisecond), record("a"))
+	queue.schedule(context.Background(), "b", now.Add(60*time.Millisecond), record("b"))
+	queue.schedule(context.Background(), "removed", now.Add(10*time.Millisecond), record("removed"))
+	queue.remove("removed")
+	// Перепланирование заменяет предыдущее время
+	queue.schedule(context.Background(), "c", now.Add(time.Millisecond), record("c"))
+
+	for range 3 {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("refreshes did not fire, got %v", fired)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"c", "a", "b"}; !slices.Equal(fired, want) {
+		t.Errorf("got order %v, want %v", fired, want)
+	}
+	if queue.Len() != 0 {
+		t.Errorf("got %d pending refreshes, want 0", queue.Len())
+	}
+}
+
+func TestQueueStopCancelsRefresh(t *testing.T) {
+	queue := NewQueue(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	canceled := make(chan struct{})
+	queue.schedule(context.Background(), "a", time.Now(), func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	})
+	time.Sleep(20 * time.Millisecond)
+	queue.Stop()
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("running refresh was not canceled by Stop")
+	}
+}
+
+func TestQueueEntryContext(t *testing.T) {
+	queue := NewQueue(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer queue.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	entry := queue.EntryContext(ctx, "a", func(context.Context) {})
+	if entry.ScheduleRefresh(time.Now().Add(time.Hour)); queue.Len() != 1 {
+		t.Fatalf("got %d pending refreshes, want 1", queue.Len())
+	}
+
+	// Отмена контекста удаляет запись, а обновление, запланированное после неё, не попадает в очередь
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for queue.Len() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if queue.Len() != 0 {
+		t.Errorf("got %d pending refreshes after cancel, want 0", queue.Len())
+	}
+	if due := entry.ScheduleRefreshLifetime(Lifetime{ExpiresAt: time.Now().Add(time.Hour)}); !due.IsZero() || queue.Len() != 0 {
+		t.Errorf("refresh was scheduled at %v after cancel", due)
+	}
+}
@@ -2,25 +2,116 @@ package scheduler
 
 import (
 	"context"
+	"github.com/ShlykovPavel/JWTAuth/clock"
 	"log/slog"
+	"sync"
 	"time"
 )
 
+const (
+	// DefaultRefreshSkew за сколько до истечения токена выполняется обновление
+	DefaultRefreshSkew = 1 * time.Minute
+	// DefaultMinRefreshInterval минимальная задержка перед обновлением
+	DefaultMinRefreshInterval = 10 * time.Second
+)
+
 type Scheduler struct {
-	timer      *time.Timer
+	settings
+	mu         sync.Mutex
+	parent     context.Context
+	timer      clock.Timer
 	cancelFunc context.CancelFunc
-	onRefresh  func()
+	onRefresh  func(ctx context.Context)
 	logger     *slog.Logger
 }
 
-func NewScheduler(onRefresh func(), logger *slog.Logger) *Scheduler {
-	return &Scheduler{
+// settings - параметры, общие для Scheduler и Queue
+type settings struct {
+	strategy RefreshStrategy
+	// minInterval и maxInterval ограничивают задержку стратегии, maxInterval 0 - без ограничения
+	minInterval time.Duration
+	maxInterval time.Duration
+	clock       clock.Clock
+}
+
+func defaultSettings() settings {
+	return settings{
+		strategy:    FixedSkew(DefaultRefreshSkew),
+		minInterval: DefaultMinRefreshInterval,
+		clock:       clock.System,
+	}
+}
+
+// refreshIn возвращает задержку перед обновлением токена со сроком действия lifetime
+func (s settings) refreshIn(lifetime Lifetime) time.Duration {
+	refreshIn := max(s.strategy.RefreshIn(s.clock.Now(), lifetime), s.minInterval)
+	if s.maxInterval > 0 {
+		refreshIn = min(refreshIn, s.maxInterval)
+	}
+	return refreshIn
+}
+
+// Option настраивает Scheduler, Queue или запись в Queue
+type Option func(*settings)
+
+// WithRefreshSkew задаёт, за сколько до истечения токена выполнять обновление.
+// Сокращение для WithRefreshStrategy(FixedSkew(skew)).
+func WithRefreshSkew(skew time.Duration) Option {
+	return WithRefreshStrategy(FixedSkew(skew))
+}
+
+// WithRefreshStrategy задаёт стратегию выбора момента обновления, по умолчанию FixedSkew(DefaultRefreshSkew)
+func WithRefreshStrategy(strategy RefreshStrategy) Option {
+	return func(s *settings) {
+		s.strategy = strategy
+	}
+}
+
+// WithRefreshInterval ограничивает задержку перед обновлением снизу и сверху.
+// Минимум защищает сервер авторизации от частых запросов (по умолчанию DefaultMinRefreshInterval),
+// максимум заставляет обновлять долгоживущие токены чаще. maxInterval 0 - без ограничения.
+func WithRefreshInterval(minInterval, maxInterval time.Duration) Option {
+	return func(s *settings) {
+		s.minInterval = minInterval
+		s.maxInterval = maxInterval
+	}
+}
+
+// WithClock задаёт источник текущего времени и таймеров
+func WithClock(c clock.Clock) Option {
+	return func(s *settings) {
+		s.clock = c
+	}
+}
+
+func NewScheduler(onRefresh func(), logger *slog.Logger, opts ...Option) *Scheduler {
+	return NewSchedulerContext(context.Background(), func(context.Context) { onRefresh() }, logger, opts...)
+}
+
+// NewSchedulerContext создаёт планировщик, привязанный к контексту.
+// После отмены ctx запланированные обновления не выполняются, а onRefresh получает контекст,
+// который отменяется вместе с ctx или при вызове Stop.
+func NewSchedulerContext(ctx context.Context, onRefresh func(ctx context.Context), logger *slog.Logger, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		settings:  defaultSettings(),
+		parent:    ctx,
 		onRefresh: onRefresh,
 		logger:    logger,
 	}
+	for _, opt := range opts {
+		opt(&s.settings)
+	}
+	return s
 }
 
 func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopUnlocked()
+}
+
+func (s *Scheduler) stopUnlocked() {
 	if s.cancelFunc != nil {
 		s.cancelFunc()
 	}
@@ -29,28 +120,43 @@ func (s *Scheduler) Stop() {
 	}
 }
 
+// ScheduleRefresh планирует обновление токена, истекающего в expiry
 func (s *Scheduler) ScheduleRefresh(expiry time.Time) {
+	s.ScheduleRefreshLifetime(Lifetime{ExpiresAt: expiry})
+}
+
+// ScheduleRefreshLifetime планирует обновление токена по стратегии, заменяя ранее запланированное.
+// В отличие от ScheduleRefresh учитывает момент выдачи токена, нужный LifetimeFraction.
+// Возвращает момент запланированного обновления или нулевое время, если контекст планировщика завершён.
+func (s *Scheduler) ScheduleRefreshLifetime(lifetime Lifetime) time.Time {
 	const op = "scheduler.scheduleRefresh"
 	log := s.logger.With(
 		slog.String("op", op))
-	s.Stop()
 
-	refreshIn := time.Until(expiry) - 1*time.Minute
-	log.Debug("Calculating time for init refresh: ", slog.Any("time to refresh", refreshIn))
-	if refreshIn < 10*time.Second {
-		refreshIn = 10 * time.Second
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopUnlocked()
+
+	if s.parent.Err() != nil {
+		log.Debug("scheduler context is done, refresh is not scheduled")
+		return time.Time{}
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	refreshIn := s.refreshIn(lifetime)
+	log.Debug("Calculating time for init refresh: ", slog.Any("time to refresh", refreshIn))
+
+	ctx, cancel := context.WithCancel(s.parent)
 	s.cancelFunc = cancel
 
-	s.timer = time.NewTimer(refreshIn)
+	timer := s.clock.NewTimer(refreshIn)
+	s.timer = timer
 	go func() {
 		select {
-		case <-s.timer.C:
-			s.onRefresh()
+		case <-timer.C():
+			s.onRefresh(ctx)
 		case <-ctx.Done():
 			s.logger.Debug("refresh canceled")
 		}
 	}()
+	return s.clock.Now().Add(refreshIn)
 }
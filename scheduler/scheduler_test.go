@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"context"
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSchedulerFakeClock(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	fired := make(chan struct{}, 1)
+	s := NewScheduler(func() { fired <- struct{}{} }, slog.New(slog.NewTextHandler(io.Discard, nil)), WithClock(fake))
+	defer s.Stop()
+
+	s.ScheduleRefresh(fake.Now().Add(time.Hour))
+	// Перепланирование заменяет предыдущий таймер
+	s.ScheduleRefresh(fake.Now().Add(2 * time.Hour))
+	if got := fake.Waiters(); got != 1 {
+		t.Fatalf("got %d timers, want 1", got)
+	}
+
+	fake.Advance(time.Hour)
+	select {
+	case <-fired:
+		t.Fatal("replaced refresh fired")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	fake.Advance(59 * time.Minute)
+	select {
+	case <-fired:
+	case <-time.After(time.Second):
+		t.Fatal("refresh did not fire")
+	}
+}
+
+func TestQueueFakeClock(t *testing.T) {
+	fake := clocktesting.NewFakeClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	queue := NewQueue(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), WithClock(fake))
+	defer queue.Stop()
+
+	fired := make(chan string, 2)
+	record := func(key string) func(context.Context) {
+		return func(context.Context) { fired <- key }
+	}
+	queue.Entry("b", record("b"), WithRefreshSkew(0)).ScheduleRefresh(fake.Now().Add(2 * time.Hour))
+	queue.Entry("a", record("a"), WithRefreshSkew(0)).ScheduleRefresh(fake.Now().Add(time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for _, want := range []string{"a", "b"} {
+		if err := fake.BlockUntil(ctx, 1); err != nil {
+			t.Fatalf("queue timer was not set: %v", err)
+		}
+		fake.Advance(time.Hour)
+		select {
+		case got := <-fired:
+			if got != want {
+				t.Errorf("got refresh %q, want %q", got, want)
+			}
+		case <-ctx.Done():
+			t.Fatalf("refresh %q did not fire", want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Lifetime - срок действия токена
+type Lifetime struct {
+	// IssuedAt момент выдачи токена (claim iat), нулевой если неизвестен
+	IssuedAt time.Time
+	// ExpiresAt момент истечения токена
+	ExpiresAt time.Time
+}
+
+// RefreshStrategy определяет, когда обновлять токен.
+// Результат ограничивается минимальным и максимальным интервалом (WithRefreshInterval).
+type RefreshStrategy interface {
+	// RefreshIn возвращает задержку от now до обновления токена со сроком действия lifetime
+	RefreshIn(now time.Time, lifetime Lifetime) time.Duration
+}
+
+// FixedSkew обновляет токен за skew до истечения. Стратегия по умолчанию с DefaultRefreshSkew.
+func FixedSkew(skew time.Duration) RefreshStrategy {
+	return fixedSkew(skew)
+}
+
+type fixedSkew time.Duration
+
+func (s fixedSkew) RefreshIn(now time.Time, lifetime Lifetime) time.Duration {
+	return lifetime.ExpiresAt.Sub(now) - time.Duration(s)
+}
+
+// LifetimeFraction обновляет токен, когда прошла доля fraction (от 0 до 1) его времени жизни,
+// например 0.8 - на 80% срока действия. Время жизни считается от IssuedAt, а если он неизвестен -
+// от текущего момента, то есть берётся доля оставшегося срока.
+func LifetimeFraction(fraction float64) RefreshStrategy {
+	return lifetimeFraction(min(max(fraction, 0), 1))
+}
+
+type lifetimeFraction float64
+
+func (f lifetimeFraction) RefreshIn(now time.Time, lifetime Lifetime) time.Duration {
+	issuedAt := lifetime.IssuedAt
+	if issuedAt.IsZero() || issuedAt.After(now) {
+		issuedAt = now
+	}
+	total := lifetime.ExpiresAt.Sub(issuedAt)
+	return issuedAt.Add(time.Duration(float64(total) * float64(f))).Sub(now)
+}
+
+// Jittered сдвигает обновление стратегии base на случайную долю до spread (от 0 до 1) раньше,
+// чтобы реплики, получившие токены одновременно, не обновляли их в один момент.
+// Например, с spread 0.1 задержка в 10 минут превращается в случайную от 9 до 10 минут.
+// Обновление никогда не сдвигается позже, чем решила base.
+func Jittered(base RefreshStrategy, spread float64) RefreshStrategy {
+	return jittered{base: base, spread: min(max(spread, 0), 1), random: rand.Float64}
+}
+
+type jittered struct {
+	base   RefreshStrategy
+	spread float64
+	// random возвращает число в [0, 1), подменяется в тестах
+	random func() float64
+}
+
+func (j jittered) RefreshIn(now time.Time, lifetime Lifetime) time.Duration {
+	d := j.base.RefreshIn(now, lifetime)
+	if d <= 0 {
+		return d
+	}
+	return d - time.Duration(float64(d)*j.spread*j.random())
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	clocktesting "github.com/ShlykovPavel/JWTAuth/clock/testing"
+	"testing"
+	"time"
+)
+
+func TestRefreshStrategy(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		TestName string
+		opts     []Option
+		lifetime Lifetime
+		expected time.Duration
+	}{
+		{
+			TestName: "DefaultSkew",
+			lifetime: Lifetime{ExpiresAt: now.Add(time.Hour)},
+			expected: 59 * time.Minute,
+		},
+		{
+			TestName: "ShortTokenUsesMinInterval",
+			lifetime: Lifetime{ExpiresAt: now.Add(30 * time.Second)},
+			expected: DefaultMinRefreshInterval,
+		},
+		{
+			TestName: "FractionOfLifetime",
+			opts:     []Option{WithRefreshStrategy(LifetimeFraction(0.8))},
+			lifetime: Lifetime{IssuedAt: now, ExpiresAt: now.Add(2 * time.Minute)},
+			expected: 96 * time.Second,
+		},
+		{
+			TestName: "FractionCountsFromIssuedAt",
+			opts:     []Option{WithRefreshStrategy(LifetimeFraction(0.5))},
+			lifetime: Lifetime{IssuedAt: now.Add(-time.Hour), ExpiresAt: now.Add(3 * time.Hour)},
+			expected: time.Hour,
+		},
+		{
+			TestName: "FractionWithoutIssuedAt",
+			opts:     []Option{WithRefreshStrategy(LifetimeFraction(0.5))},
+			lifetime: Lifetime{ExpiresAt: now.Add(time.Hour)},
+			expected: 30 * time.Minute,
+		},
+		{
+			TestName: "MaxInterval",
+			opts:     []Option{WithRefreshInterval(time.Second, time.Hour)},
+			lifetime: Lifetime{ExpiresAt: now.Add(24 * time.Hour)},
+			expected: time.Hour,
+		},
+		{
+			TestName: "MinInterval",
+			opts:     []Option{WithRefreshSkew(time.Minute), WithRefreshInterval(time.Minute, 0)},
+			lifetime: Lifetime{ExpiresAt: now.Add(90 * time.Second)},
+			expected: time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.TestName, func(t *testing.T) {
+			s := defaultSettings()
+			s.clock = clocktesting.NewFakeClock(now)
+			for _, opt := range tt.opts {
+				opt(&s)
+			}
+			if got := s.refreshIn(tt.lifetime); got != tt.expected {
+				t.Errorf("refreshIn() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJittered(t *testing.T) {
+	now := time.Now()
+	lifetime := Lifetime{ExpiresAt: now.Add(11 * time.Minute)}
+	strategy := Jittered(FixedSkew(time.Minute), 0.1).(jittered)
+
+	for _, tt := range []struct {
+		random   float64
+		expected time.Duration
+	}{
+		{0, 10 * time.Minute},
+		{0.5, 9*time.Minute + 30*time.Second},
+		{1, 9 * time.Minute},
+	} {
+		strategy.random = func() float64 { return tt.random }
+		if got := strategy.RefreshIn(now, lifetime); got != tt.expected {
+			t.Errorf("random %v: RefreshIn() = %v, want %v", tt.random, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tokenstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Sealer шифрует содержимое файла хранилища. Open должен обнаруживать изменённые данные и возвращать
+// для них ErrCorrupted: только такой файл File перезаписывает при сохранении. Для данных, зашифрованных
+// неизвестным ключом, возвращается ErrUnknownKey, и файл сохраняется как есть: его может читать реплика с новым ключом
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+// File хранит токены в JSON файле с правами 0600.
+// Запись атомарна: данные пишутся во временный файл в том же каталоге и переименовываются,
+// поэтому читатель, в том числе другая реплика, никогда не увидит файл наполовину.
+// Save читает файл, добавляет запись и записывает его под блокировкой flock на соседний файл path+".lock",
+// поэтому реплики, сохраняющие токены одновременно, не теряют записи друг друга. На платформах без flock
+// (например, Windows) блокировка действует только внутри процесса.
+type File struct {
+	path   string
+	sealer Sealer
+	mu     sync.Mutex
+}
+
+// FileOption настраивает File
+type FileOption func(*File)
+
+// WithSealer шифрует файл хранилища
+func WithSealer(sealer Sealer) FileOption {
+	return func(f *File) {
+		f.sealer = sealer
+	}
+}
+
+// NewFile создаёт хранилище в файле path. Файл создаётся при первом сохранении
+func NewFile(path string, opts ...FileOption) *File {
+	f := &File{path: path}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// fileRecord - токены в файле. В отличие от requests.Tokens сохраняется и срок действия
+type fileRecord struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	TokenType    string    `json:"tokenType,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+func (f *File) Load(_ context.Context, key string) (*requests.Tokens, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	records, err := f.readUnlocked()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := records[key]
+	if !ok {
+		return nil, nil
+	}
+	return &requests.Tokens{
+		AccessToken:  record.AccessToken,
+		RefreshToken: record.RefreshToken,
+		TokenType:    record.TokenType,
+		ExpiresAt:    record.ExpiresAt,
+	}, nil
+}
+
+func (f *File) Save(_ context.Context, key string, tokens requests.Tokens) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	unlock, err := lockFile(f.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("lock token store: %w", err)
+	}
+	defer unlock()
+
+	records, err := f.readUnlocked()
+	if err != nil {
+		// Перезаписывается только повреждённый файл. Временная ошибка чтения (права, ввод-вывод) или файл,
+		// зашифрованный ключом, которого у этой реплики ещё нет, не должны стирать токены остальных учётных записей
+		if !replaceable(err) {
+			return err
+		}
+		records = make(map[string]fileRecord)
+	}
+	records[key] = fileRecord{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		TokenType:    tokens.TokenType,
+		ExpiresAt:    tokens.ExpiresAt,
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	if f.sealer != nil {
+		if data, err = f.sealer.Seal(data); err != nil {
+			return fmt.Errorf("seal token store: %w", err)
+		}
+	}
+	return writeFileAtomic(f.path, data)
+}
+
+func (f *File) readUnlocked() (map[string]fileRecord, error) {
+	records := make(map[string]fileRecord)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.sealer != nil {
+		if data, err = f.sealer.Open(data); err != nil {
+			return nil, fmt.Errorf("open token store: %w", err)
+		}
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parse token store: %w", err)
+	}
+	return records, nil
+}
+
+// replaceable сообщает, что файл хранилища не может быть прочитан никогда и его можно перезаписать
+func replaceable(err error) bool {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	return errors.Is(err, ErrCorrupted) || errors.As(err, &syntaxErr) || errors.As(err, &typeErr)
+}
+
+// writeFileAtomic записывает файл через временный файл и rename
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp создаёт файл с правами 0600, но права задаются явно на случай другой umask
+	if err := tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,182 @@
+package tokenstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// reverseSealer - тестовый Sealer: переворачивает данные и проверяет префикс
+type reverseSealer struct{}
+
+func (reverseSealer) Seal(plaintext []byte) ([]byte, error) {
+	sealed := append([]byte("sealed:"), plaintext...)
+	for i, j := 0, len(sealed)-1; i < j; i, j = i+1, j-1 {
+		sealed[i], sealed[j] = sealed[j], sealed[i]
+	}
+	return sealed, nil
+}
+
+func (reverseSealer) Open(ciphertext []byte) ([]byte, error) {
+	opened := bytes.Clone(ciphertext)
+	for i, j := 0, len(opened)-1; i < j; i, j = i+1, j-1 {
+		opened[i], opened[j] = opened[j], opened[i]
+	}
+	plaintext, ok := bytes.CutPrefix(opened, []byte("sealed:"))
+	if !ok {
+		return nil, ErrCorrupted
+	}
+	return plaintext, nil
+}
+
+func TestFile(t *testing.T) {
+	tests := []struct {
+		testName string
+		opts     []FileOption
+	}{
+		{"Plain", nil},
+		{"Sealed", []FileOption{WithSealer(reverseSealer{})}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.json")
+			store := NewFile(path, tt.opts...)
+			ctx := context.Background()
+
+			if tokens, err := store.Load(ctx, "a"); err != nil || tokens != nil {
+				t.Fatalf("got %v, %v from missing file, want nil, nil", tokens, err)
+			}
+
+			expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+			want := requests.Tokens{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer", ExpiresAt: expiresAt}
+			if err := store.Save(ctx, "a", want); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+			if err := store.Save(ctx, "b", requests.Tokens{AccessToken: "other"}); err != nil {
+				t.Fatalf("Save failed: %v", err)
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0o600 {
+				t.Errorf("got file permissions %o, want 600", perm)
+			}
+			// Кроме самого файла остаётся только файл блокировки
+			entries, _ := os.ReadDir(filepath.Dir(path))
+			for _, entry := range entries {
+				if name := entry.Name(); name != filepath.Base(path) && name != filepath.Base(path)+".lock" {
+					t.Errorf("temporary file left: %s", name)
+				}
+			}
+			data, _ := os.ReadFile(path)
+			if sealed := len(tt.opts) > 0; sealed == bytes.Contains(data, []byte(`"accessToken":"access"`)) {
+				t.Errorf("unexpected file content for sealed=%v: %s", sealed, data)
+			}
+
+			// Новый экземпляр читает то, что записал предыдущий процесс
+			got, err := NewFile(path, tt.opts...).Load(ctx, "a")
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+			if got == nil || *got != want {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFileCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	if err := os.WriteFile(path, []byte("garbage"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store := NewFile(path, WithSealer(reverseSealer{}))
+	if _, err := store.Load(context.Background(), "a"); err == nil {
+		t.Fatal("expected error for corrupted file")
+	}
+	// Повреждённый файл перезаписывается при следующем сохранении
+	if err := store.Save(context.Background(), "a", requests.Tokens{AccessToken: "access"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if tokens, err := store.Load(context.Background(), "a"); err != nil || tokens.AccessToken != "access" {
+		t.Errorf("got %v, %v after overwrite", tokens, err)
+	}
+}
+
+// failingSealer - Sealer, который не может расшифровать данные, например недоступен KMS или нет нового ключа
+type failingSealer struct {
+	reverseSealer
+	err error
+}
+
+func (s failingSealer) Open([]byte) ([]byte, error) {
+	return nil, s.err
+}
+
+func TestFileSaveKeepsUnreadableFile(t *testing.T) {
+	tests := []struct {
+		testName string
+		err      error
+	}{
+		{"NegativeKeyServiceUnavailable", errors.New("key service unavailable")},
+		{"NegativeUnknownKey", fmt.Errorf("%w: %q", ErrUnknownKey, "2025")},
+		{"NegativeCipherMismatch", ErrCipherMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.testName, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tokens.json")
+			if err := NewFile(path, WithSealer(reverseSealer{})).Save(context.Background(), "other", requests.Tokens{AccessToken: "other"}); err != nil {
+				t.Fatal(err)
+			}
+			before, _ := os.ReadFile(path)
+
+			// Ошибка чтения возвращается, а токены другой учётной записи остаются в файле
+			store := NewFile(path, WithSealer(failingSealer{err: tt.err}))
+			if err := store.Save(context.Background(), "a", requests.Tokens{AccessToken: "access"}); !errors.Is(err, tt.err) {
+				t.Fatalf("got %v, want %v", err, tt.err)
+			}
+			if after, _ := os.ReadFile(path); !bytes.Equal(before, after) {
+				t.Error("unreadable file was overwritten")
+			}
+			if tokens, err := NewFile(path, WithSealer(reverseSealer{})).Load(context.Background(), "other"); err != nil || tokens.AccessToken != "other" {
+				t.Errorf("got %v, %v for other identity", tokens, err)
+			}
+		})
+	}
+}
+
+func TestFileConcurrentSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	// Отдельные File с общим путём ведут себя как реплики: мьютекс у каждого свой, общая только блокировка файла
+	const replicas = 8
+	var wg sync.WaitGroup
+	for i := range replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key := fmt.Sprintf("replica-%d", i)
+			if err := NewFile(path).Save(context.Background(), key, requests.Tokens{AccessToken: key}); err != nil {
+				t.Errorf("Save failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	store := NewFile(path)
+	for i := range replicas {
+		key := fmt.Sprintf("replica-%d", i)
+		if tokens, err := store.Load(context.Background(), key); err != nil || tokens == nil {
+			t.Errorf("got %v, %v for %s", tokens, err, key)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package tokenstore
+
+// lockFile на платформах без flock не блокирует файл: сохранения одного процесса упорядочивает мьютекс File,
+// а одновременные сохранения из разных процессов могут потерять записи друг друга
+func lockFile(string) (unlock func(), err error) {
+	return func() {}, nil
+}
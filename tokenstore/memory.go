@@ -0,0 +1,35 @@
+// Package tokenstore содержит реализации auth.TokenStore: в памяти, в файле и в зашифрованном файле.
+package tokenstore
+
+import (
+	"context"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"sync"
+)
+
+// Memory хранит токены в памяти процесса. Подходит для тестов и для нескольких JWTAuth в одном процессе
+type Memory struct {
+	mu     sync.RWMutex
+	tokens map[string]requests.Tokens
+}
+
+func NewMemory() *Memory {
+	return &Memory{tokens: make(map[string]requests.Tokens)}
+}
+
+func (m *Memory) Load(_ context.Context, key string) (*requests.Tokens, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	tokens, ok := m.tokens[key]
+	if !ok {
+		return nil, nil
+	}
+	return &tokens, nil
+}
+
+func (m *Memory) Save(_ context.Context, key string, tokens requests.Tokens) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[key] = tokens
+	return nil
+}
@@ -0,0 +1,178 @@
+package tokenstore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"os"
+)
+
+// Cipher - алгоритм шифрования хранилища
+type Cipher byte
+
+const (
+	// AESGCM - AES-256-GCM, аппаратно ускоряется на большинстве серверов
+	AESGCM Cipher = iota + 1
+	// XChaCha20Poly1305 - XChaCha20-Poly1305 со 192-битным nonce, быстр без AES-NI
+	XChaCha20Poly1305
+)
+
+// KeySize размер ключа шифрования в байтах
+const KeySize = 32
+
+var (
+	// ErrUnknownKey данные зашифрованы ключом, которого нет в AEAD
+	ErrUnknownKey = errors.New("unknown encryption key")
+	// ErrCorrupted данные повреждены или изменены
+	ErrCorrupted = errors.New("sealed data is corrupted")
+	// ErrCipherMismatch данные зашифрованы другим алгоритмом, чем настроен в AEAD
+	ErrCipherMismatch = errors.New("sealed with a different cipher")
+)
+
+// Key - ключ шифрования. ID сохраняется рядом с шифротекстом и позволяет ротировать ключи
+type Key struct {
+	ID     string
+	Secret []byte
+}
+
+// Параметры Argon2id по RFC 9106, раздел 4 (второй рекомендуемый вариант)
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+)
+
+// KeyFromPassphrase получает ключ из пароля через Argon2id.
+// salt должна быть постоянной для пароля (например, имя сервиса), иначе сохранённые данные не расшифруются.
+func KeyFromPassphrase(id, passphrase string, salt []byte) (Key, error) {
+	if passphrase == "" {
+		return Key{}, errors.New("empty passphrase")
+	}
+	if len(salt) < 8 {
+		return Key{}, errors.New("salt must be at least 8 bytes")
+	}
+	secret := argon2.IDKey([]byte(passphrase), salt, argon2Time, argon2Memory, argon2Threads, KeySize)
+	return Key{ID: id, Secret: secret}, nil
+}
+
+// KeyFromFile читает ключ из файла: 32 байта как есть или в base64.
+// Новый ключ можно создать командой `openssl rand -base64 32`.
+func KeyFromFile(id, path string) (Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Key{}, err
+	}
+	if len(data) == KeySize {
+		return Key{ID: id, Secret: data}, nil
+	}
+	secret, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data)))
+	if err != nil || len(secret) != KeySize {
+		return Key{}, fmt.Errorf("key file %s must contain %d bytes or their base64", path, KeySize)
+	}
+	return Key{ID: id, Secret: secret}, nil
+}
+
+// AEAD - Sealer с аутентифицированным шифрованием.
+// Данные шифруются основным ключом, а расшифровываются ключом, ID которого записан в заголовке.
+// Для ротации новый ключ передаётся основным, а старые - дополнительными:
+// файл, зашифрованный старым ключом, читается и при следующем сохранении перешифровывается новым.
+type AEAD struct {
+	cipher  Cipher
+	primary Key
+	keys    map[string]cipher.AEAD
+}
+
+// sealedMagic - сигнатура формата: magic, версия, алгоритм, длина ID ключа, ID ключа, nonce, шифротекст.
+// Заголовок целиком входит в additional data и тоже защищён от изменения.
+var sealedMagic = []byte("JWTS\x01")
+
+// NewAEAD создаёт Sealer с основным ключом primary и ключами old для чтения данных после ротации
+func NewAEAD(c Cipher, primary Key, old ...Key) (*AEAD, error) {
+	s := &AEAD{cipher: c, primary: primary, keys: make(map[string]cipher.AEAD)}
+	for _, key := range append([]Key{primary}, old...) {
+		if len(key.ID) > 255 {
+			return nil, fmt.Errorf("key id %q is too long", key.ID)
+		}
+		if _, ok := s.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		aead, err := newAEAD(c, key.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key.ID, err)
+		}
+		s.keys[key.ID] = aead
+	}
+	return s, nil
+}
+
+func newAEAD(c Cipher, secret []byte) (cipher.AEAD, error) {
+	if len(secret) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes", KeySize)
+	}
+	switch c {
+	case AESGCM:
+		block, err := aes.NewCipher(secret)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case XChaCha20Poly1305:
+		return chacha20poly1305.NewX(secret)
+	}
+	return nil, fmt.Errorf("unsupported cipher %d", c)
+}
+
+func (s *AEAD) Seal(plaintext []byte) ([]byte, error) {
+	aead := s.keys[s.primary.ID]
+	header := append(bytes.Clone(sealedMagic), byte(s.cipher), byte(len(s.primary.ID)))
+	header = append(header, s.primary.ID...)
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sealed := append(bytes.Clone(header), nonce...)
+	return aead.Seal(sealed, nonce, plaintext, header), nil
+}
+
+func (s *AEAD) Open(ciphertext []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(ciphertext, sealedMagic)
+	if !ok || len(rest) < 2 {
+		return nil, ErrCorrupted
+	}
+	if Cipher(rest[0]) != s.cipher {
+		return nil, fmt.Errorf("%w: %d", ErrCipherMismatch, rest[0])
+	}
+	idLen := int(rest[1])
+	rest = rest[2:]
+	if len(rest) < idLen {
+		return nil, ErrCorrupted
+	}
+	keyID := string(rest[:idLen])
+	aead, ok := s.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	rest = rest[idLen:]
+	if len(rest) < aead.NonceSize() {
+		return nil, ErrCorrupted
+	}
+	header := ciphertext[:len(ciphertext)-len(rest)]
+	nonce, sealed := rest[:aead.NonceSize()], rest[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, sealed, header)
+	if err != nil {
+		return nil, ErrCorrupted
+	}
+	return plaintext, nil
+}
+
+// NewEncryptedFile создаёт файловое хранилище, зашифрованное sealer
+func NewEncryptedFile(path string, sealer *AEAD) *File {
+	return NewFile(path, WithSealer(sealer))
+}
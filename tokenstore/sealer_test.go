@@ -0,0 +1,142 @@
+package tokenstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/ShlykovPavel/JWTAuth/http-server/requests"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestKey(t *testing.T, id string) Key {
+	t.Helper()
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		t.Fatal(err)
+	}
+	return Key{ID: id, Secret: secret}
+}
+
+func TestAEAD(t *testing.T) {
+	for _, c := range []Cipher{AESGCM, XChaCha20Poly1305} {
+		oldKey, newKey := newTestKey(t, "2024"), newTestKey(t, "2025")
+		oldSealer, err := NewAEAD(c, oldKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotated, err := NewAEAD(c, newKey, oldKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		newOnly, err := NewAEAD(c, newKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherCipher, err := NewAEAD(AESGCM+XChaCha20Poly1305-c, oldKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		plaintext := []byte(`{"accessToken":"secret"}`)
+		sealed, err := oldSealer.Seal(plaintext)
+		if err != nil {
+			t.Fatalf("Seal failed: %v", err)
+		}
+		if bytes.Contains(sealed, []byte("secret")) {
+			t.Error("sealed data contains plaintext")
+		}
+
+		tests := []struct {
+			testName string
+			sealer   *AEAD
+			data     []byte
+			wantErr  error
+		}{
+			{"PositiveSameKey", oldSealer, sealed, nil},
+			{"PositiveRotatedKey", rotated, sealed, nil},
+			{"NegativeRemovedKey", newOnly, sealed, ErrUnknownKey},
+			{"NegativeOtherCipher", otherCipher, sealed, ErrCipherMismatch},
+			{"NegativeTamperedCiphertext", oldSealer, flipLastByte(sealed), ErrCorrupted},
+			{"NegativeTamperedKeyID", rotated, bytes.Replace(sealed, []byte("2024"), []byte("2025"), 1), ErrCorrupted},
+			{"NegativeTruncated", oldSealer, sealed[:8], ErrCorrupted},
+		}
+		for _, tt := range tests {
+			t.Run(tt.testName, func(t *testing.T) {
+				got, err := tt.sealer.Open(tt.data)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("expected %v, got %v", tt.wantErr, err)
+					}
+					return
+				}
+				if err != nil || !bytes.Equal(got, plaintext) {
+					t.Fatalf("got %q, %v", got, err)
+				}
+			})
+		}
+	}
+}
+
+func flipLastByte(data []byte) []byte {
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	return tampered
+}
+
+func TestKeyDerivation(t *testing.T) {
+	salt := []byte("billing-service")
+	first, err := KeyFromPassphrase("v1", "correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := KeyFromPassphrase("v1", "correct horse", salt)
+	otherSalt, _ := KeyFromPassphrase("v1", "correct horse", []byte("crm-service"))
+	if !bytes.Equal(first.Secret, second.Secret) || len(first.Secret) != KeySize {
+		t.Error("derivation must be deterministic")
+	}
+	if bytes.Equal(first.Secret, otherSalt.Secret) {
+		t.Error("different salts must give different keys")
+	}
+	if _, err := KeyFromPassphrase("v1", "", salt); err == nil {
+		t.Error("expected error for empty passphrase")
+	}
+
+	path := filepath.Join(t.TempDir(), "key")
+	os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(first.Secret)+"\n"), 0o600)
+	fromFile, err := KeyFromFile("v1", path)
+	if err != nil || !bytes.Equal(fromFile.Secret, first.Secret) {
+		t.Errorf("got %v, %v from key file", fromFile, err)
+	}
+	os.WriteFile(path, []byte("short"), 0o600)
+	if _, err := KeyFromFile("v1", path); err == nil {
+		t.Error("expected error for short key")
+	}
+}
+
+// TestEncryptedFileRotation Файл, зашифрованный старым ключом, читается после ротации и перешифровывается новым
+func TestEncryptedFileRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.enc")
+	oldKey, newKey := newTestKey(t, "old"), newTestKey(t, "new")
+	oldSealer, _ := NewAEAD(XChaCha20Poly1305, oldKey)
+	rotated, _ := NewAEAD(XChaCha20Poly1305, newKey, oldKey)
+	newOnly, _ := NewAEAD(XChaCha20Poly1305, newKey)
+	ctx := context.Background()
+
+	if err := NewEncryptedFile(path, oldSealer).Save(ctx, "a", requests.Tokens{AccessToken: "access", RefreshToken: "refresh"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	store := NewEncryptedFile(path, rotated)
+	tokens, err := store.Load(ctx, "a")
+	if err != nil || tokens.RefreshToken != "refresh" {
+		t.Fatalf("got %v, %v after rotation", tokens, err)
+	}
+	if err := store.Save(ctx, "b", requests.Tokens{AccessToken: "other"}); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if tokens, err := NewEncryptedFile(path, newOnly).Load(ctx, "a"); err != nil || tokens.AccessToken != "access" {
+		t.Errorf("file was not re-encrypted with the new key: %v, %v", tokens, err)
+	}
+}